@@ -0,0 +1,263 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package captions
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	rows    = 15
+	columns = 32
+)
+
+const (
+	modePopOn = iota
+	modeRollUp
+	modePaintOn
+	modeText
+)
+
+type screen [rows][columns]rune
+
+func (s *screen) clear() {
+	*s = screen{}
+}
+
+func (s *screen) text() string {
+	var lines []string
+	for _, row := range s {
+		line := strings.TrimSpace(strings.Map(func(r rune) rune {
+			if r == 0 {
+				return ' '
+			}
+			return r
+		}, string(row[:])))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Characters of the basic set that differ from ASCII.
+var basicChars = map[byte]rune{
+	0x2A: 'á', 0x5C: 'é', 0x5E: 'í', 0x5F: 'ó', 0x60: 'ú',
+	0x7B: 'ç', 0x7C: '÷', 0x7D: 'Ñ', 0x7E: 'ñ', 0x7F: '█',
+}
+
+var specialChars = []rune("®°½¿™¢£♪à èâêîôû")
+
+var extendedChars = map[byte][]rune{
+	0x12: []rune("ÁÉÓÚÜü‘¡*'—©℠•“”ÀÂÇÈÊËëÎÏïÔÙùÛ«»"),
+	0x13: []rune("ÃãÍÌìÒòÕõ{}\\^_|~ÄäÖöß¥¤│ÅåØø┌┐└┘"),
+}
+
+// PAC rows indexed by the low three bits of the first byte, the second
+// byte selects the upper or lower row of the pair.
+var pacRows = [8][2]int{
+	{10, 10}, {0, 1}, {2, 3}, {11, 12}, {13, 14}, {4, 5}, {6, 7}, {8, 9},
+}
+
+// Decoder608 decodes CEA-608 caption channel 1 from field 1 data.
+type Decoder608 struct {
+	mode      int
+	rollRows  int
+	displayed screen
+	hidden    screen
+	row, col  int
+	channel   int
+	lastCode  [2]byte
+	builder   cueBuilder
+	lastTime  time.Duration
+}
+
+func NewDecoder608() *Decoder608 {
+	return &Decoder608{channel: 1, row: rows - 1}
+}
+
+func (d *Decoder608) memory() *screen {
+	if d.mode == modePopOn {
+		return &d.hidden
+	}
+	return &d.displayed
+}
+
+func (d *Decoder608) put(r rune) {
+	if d.mode == modeText {
+		return
+	}
+	mem := d.memory()
+	if d.col >= columns {
+		d.col = columns - 1
+	}
+	mem[d.row][d.col] = r
+	d.col++
+}
+
+func (d *Decoder608) backspace() {
+	if d.col > 0 {
+		d.col--
+		d.memory()[d.row][d.col] = 0
+	}
+}
+
+func (d *Decoder608) update(t time.Duration) {
+	d.builder.show(t, d.displayed.text())
+}
+
+func (d *Decoder608) rollUp() {
+	top := d.row - d.rollRows + 1
+	if top < 0 {
+		top = 0
+	}
+	for r := 0; r < rows; r++ {
+		if r < top || r > d.row {
+			d.displayed[r] = [columns]rune{}
+		}
+	}
+	for r := top; r < d.row; r++ {
+		d.displayed[r] = d.displayed[r+1]
+	}
+	d.displayed[d.row] = [columns]rune{}
+	d.col = 0
+}
+
+func (d *Decoder608) control(t time.Duration, b1, b2 byte) {
+	switch {
+	case (b1 == 0x14 || b1 == 0x15) && b2 >= 0x20 && b2 <= 0x2F:
+		switch b2 {
+		case 0x20:
+			d.mode = modePopOn
+		case 0x21:
+			d.backspace()
+		case 0x24:
+			mem := d.memory()
+			for c := d.col; c < columns; c++ {
+				mem[d.row][c] = 0
+			}
+		case 0x25, 0x26, 0x27:
+			if d.mode != modeRollUp {
+				d.displayed.clear()
+				d.hidden.clear()
+			}
+			d.mode = modeRollUp
+			d.rollRows = int(b2-0x25) + 2
+			d.col = 0
+		case 0x29:
+			d.mode = modePaintOn
+		case 0x2A, 0x2B:
+			d.mode = modeText
+		case 0x2C:
+			d.displayed.clear()
+		case 0x2D:
+			if d.mode == modeRollUp {
+				d.rollUp()
+			} else if d.row < rows-1 {
+				d.row++
+				d.col = 0
+			}
+		case 0x2E:
+			d.hidden.clear()
+		case 0x2F:
+			d.displayed, d.hidden = d.hidden, d.displayed
+			d.mode = modePopOn
+		}
+		if b2 != 0x21 && b2 != 0x24 || d.mode == modePaintOn {
+			d.update(t)
+		}
+	case b1 == 0x17 && b2 >= 0x21 && b2 <= 0x23:
+		d.col += int(b2 - 0x20)
+		if d.col >= columns {
+			d.col = columns - 1
+		}
+	case b1 == 0x11 && b2 >= 0x20 && b2 <= 0x2F:
+		d.put(' ')
+	case b1 == 0x11 && b2 >= 0x30 && b2 <= 0x3F:
+		d.put(specialChars[b2-0x30])
+	case (b1 == 0x12 || b1 == 0x13) && b2 >= 0x20 && b2 <= 0x3F:
+		d.backspace()
+		d.put(extendedChars[b1][b2-0x20])
+	case b2 >= 0x40 && b2 <= 0x7F:
+		row := pacRows[b1&0x07][(b2>>5)&0x01]
+		if d.mode == modeRollUp && row != d.row {
+			// Move the roll-up window to the new base row.
+			shift := row - d.row
+			var moved screen
+			for r := 0; r < rows; r++ {
+				if nr := r + shift; nr >= 0 && nr < rows {
+					moved[nr] = d.displayed[r]
+				}
+			}
+			d.displayed = moved
+		}
+		d.row = row
+		d.col = 0
+		if b2&0x10 != 0 {
+			d.col = int((b2>>1)&0x07) * 4
+		}
+	}
+}
+
+// Decode processes one pair of field 1 bytes.
+func (d *Decoder608) Decode(t time.Duration, data [2]byte) {
+	d.lastTime = t
+	b1, b2 := data[0]&0x7F, data[1]&0x7F
+
+	if b1 == 0 && b2 == 0 {
+		return
+	}
+
+	if b1 >= 0x10 && b1 <= 0x1F {
+		// Control codes are transmitted twice, ignore the repeat.
+		code := [2]byte{b1, b2}
+		if code == d.lastCode {
+			d.lastCode = [2]byte{}
+			return
+		}
+		d.lastCode = code
+
+		d.channel = 1
+		if b1&0x08 != 0 {
+			d.channel = 2
+		}
+		if d.channel == 1 {
+			d.control(t, b1&0x17, b2)
+		}
+		return
+	}
+	d.lastCode = [2]byte{}
+
+	if d.channel != 1 {
+		return
+	}
+	for _, b := range []byte{b1, b2} {
+		if b < 0x20 {
+			continue
+		}
+		if r, ok := basicChars[b]; ok {
+			d.put(r)
+		} else {
+			d.put(rune(b))
+		}
+	}
+}
+
+func (d *Decoder608) Cues() []Cue {
+	d.builder.flush(d.lastTime)
+	return d.builder.cues
+}
@@ -0,0 +1,106 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package captions
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Channel 1 control codes, sent twice as broadcasters do.
+var (
+	rcl = []byte{0x14, 0x20, 0x14, 0x20}
+	ru3 = []byte{0x14, 0x26, 0x14, 0x26}
+	edm = []byte{0x14, 0x2C, 0x14, 0x2C}
+	cr  = []byte{0x14, 0x2D, 0x14, 0x2D}
+	eoc = []byte{0x14, 0x2F, 0x14, 0x2F}
+	pac = []byte{0x14, 0x70, 0x14, 0x70}
+)
+
+func codes(parts ...interface{}) []byte {
+	var out []byte
+	for _, p := range parts {
+		switch v := p.(type) {
+		case string:
+			out = append(out, v...)
+			if len(v)%2 != 0 {
+				out = append(out, 0)
+			}
+		case []byte:
+			out = append(out, v...)
+		}
+	}
+	return out
+}
+
+type step608 struct {
+	at   time.Duration
+	data []byte
+}
+
+func TestDecoder608(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step608
+		cues  []Cue
+	}{
+		{"pop-on", []step608{
+			{0, codes(rcl, pac, "HELLO")},
+			{time.Second, eoc},
+			{3 * time.Second, edm},
+		}, []Cue{{time.Second, 3 * time.Second, "HELLO"}}},
+		{"special characters", []step608{
+			{0, codes(rcl, pac, []byte{0x11, 0x37}, "LA", []byte{0x2A, 'E'}, []byte{0x12, 0x21})},
+			{time.Second, eoc},
+			{2 * time.Second, edm},
+		}, []Cue{{time.Second, 2 * time.Second, "♪LAáÉ"}}},
+		{"parity bits", []step608{
+			{0, codes(rcl, pac, []byte{0xC8, 0x49})},
+			{time.Second, []byte{0x94, 0x2F, 0x94, 0x2F}},
+			{2 * time.Second, edm},
+		}, []Cue{{time.Second, 2 * time.Second, "HI"}}},
+		{"roll-up", []step608{
+			{0, codes(ru3, "ONE")},
+			{5 * time.Second, cr},
+			{5*time.Second + 500*time.Millisecond, codes("TWO")},
+			{6 * time.Second, cr},
+			{8 * time.Second, edm},
+		}, []Cue{{5 * time.Second, 6 * time.Second, "ONE"}, {6 * time.Second, 8 * time.Second, "ONE\nTWO"}}},
+		{"channel 2", []step608{
+			{0, codes([]byte{0x1C, 0x20, 0x1C, 0x20, 0x1C, 0x70, 0x1C, 0x70}, "NO")},
+			{time.Second, []byte{0x1C, 0x2F, 0x1C, 0x2F}},
+			{2 * time.Second, []byte{0x1C, 0x2C, 0x1C, 0x2C}},
+		}, nil},
+		{"never cleared", []step608{
+			{0, codes(rcl, pac, "HELLO")},
+			{time.Second, eoc},
+			{30 * time.Second, []byte{0x80, 0x80}},
+		}, []Cue{{time.Second, 11 * time.Second, "HELLO"}}},
+	}
+
+	for _, tt := range tests {
+		d := NewDecoder608()
+		for _, s := range tt.steps {
+			for i := 0; i+1 < len(s.data); i += 2 {
+				d.Decode(s.at, [2]byte{s.data[i], s.data[i+1]})
+			}
+		}
+		if cues := d.Cues(); !reflect.DeepEqual(cues, tt.cues) {
+			t.Errorf("%s: got %q, want %q", tt.name, cues, tt.cues)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package captions
+
+import (
+	"strings"
+	"time"
+)
+
+type window struct {
+	defined bool
+	visible bool
+	rows    int
+	row     int
+	col     int
+	text    [rows][]rune
+}
+
+func (w *window) clear() {
+	w.text = [rows][]rune{}
+	w.row, w.col = 0, 0
+}
+
+func (w *window) put(r rune) {
+	if w.row >= rows {
+		return
+	}
+	line := w.text[w.row]
+	for len(line) <= w.col {
+		line = append(line, ' ')
+	}
+	line[w.col] = r
+	w.text[w.row] = line
+	w.col++
+}
+
+func (w *window) newline() {
+	w.col = 0
+	if w.row+1 < w.rows {
+		w.row++
+		return
+	}
+	// Scroll the window up by a row.
+	copy(w.text[:], w.text[1:])
+	w.text[rows-1] = nil
+}
+
+func (w *window) String() string {
+	var lines []string
+	for _, line := range w.text {
+		if l := strings.TrimSpace(string(line)); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Decoder708 decodes CEA-708 caption service 1. Pen and window styles are
+// ignored, only the text of visible windows is kept.
+type Decoder708 struct {
+	windows  [8]window
+	current  int
+	packet   []byte
+	size     int
+	builder  cueBuilder
+	lastTime time.Duration
+}
+
+func NewDecoder708() *Decoder708 {
+	return &Decoder708{}
+}
+
+// Decode processes one cc_data construct of type 2 or 3.
+func (d *Decoder708) Decode(t time.Duration, ccType uint8, data [2]byte) {
+	d.lastTime = t
+
+	if ccType == 3 {
+		d.processPacket(t)
+		size := int(data[0] & 0x3F)
+		if size == 0 {
+			size = 64
+		}
+		d.size = size * 2
+		d.packet = append(d.packet[:0], data[0], data[1])
+	} else if ccType == 2 && d.packet != nil {
+		d.packet = append(d.packet, data[0], data[1])
+	} else {
+		return
+	}
+
+	if len(d.packet) >= d.size {
+		d.processPacket(t)
+	}
+}
+
+func (d *Decoder708) processPacket(t time.Duration) {
+	pkt := d.packet
+	d.packet = nil
+	if len(pkt) < 2 {
+		return
+	}
+	if len(pkt) > d.size {
+		pkt = pkt[:d.size]
+	}
+
+	// Skip the packet header and walk the service blocks.
+	b := pkt[1:]
+	for len(b) > 0 {
+		service := int(b[0] >> 5)
+		size := int(b[0] & 0x1F)
+		b = b[1:]
+		if service == 0 || size == 0 {
+			break
+		}
+		if service == 7 {
+			if len(b) == 0 {
+				break
+			}
+			service = int(b[0] & 0x3F)
+			b = b[1:]
+		}
+		if size > len(b) {
+			size = len(b)
+		}
+		if service == 1 {
+			d.serviceBlock(t, b[:size])
+		}
+		b = b[size:]
+	}
+}
+
+func (d *Decoder708) win() *window {
+	return &d.windows[d.current]
+}
+
+// Number of parameter bytes following each C1 command code 0x80-0x9F.
+var c1Params = [32]int{
+	0, 0, 0, 0, 0, 0, 0, 0, // CW0-CW7
+	1, 1, 1, 1, 1, 1, 0, 0, // CLW, DSW, HDW, TGW, DLW, DLY, DLC, RST
+	2, 3, 2, -1, -1, -1, -1, 4, // SPA, SPC, SPL, reserved, SWA
+	6, 6, 6, 6, 6, 6, 6, 6, // DF0-DF7
+}
+
+func (d *Decoder708) serviceBlock(t time.Duration, b []byte) {
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == 0x10:
+			// EXT1: skip the extended code and any parameters.
+			i++
+			if i < len(b) {
+				switch e := b[i]; {
+				case e < 0x08:
+				case e < 0x10:
+					i++
+				case e < 0x18:
+					i += 2
+				case e < 0x20:
+					i += 3
+				case e >= 0x20 && e < 0x80 || e >= 0xA0:
+					d.win().put('_')
+				}
+			}
+		case c == 0x03:
+			d.update(t)
+		case c == 0x08:
+			w := d.win()
+			if w.col > 0 {
+				w.col--
+			}
+		case c == 0x0C:
+			d.win().clear()
+		case c == 0x0D:
+			d.win().newline()
+			d.update(t)
+		case c >= 0x11 && c < 0x18:
+			i++
+		case c >= 0x18 && c < 0x20:
+			i += 2
+		case c < 0x20:
+		case c < 0x80:
+			if c == 0x7F {
+				d.win().put('♪')
+			} else {
+				d.win().put(rune(c))
+			}
+		case c < 0xA0:
+			n := c1Params[c-0x80]
+			if n < 0 {
+				continue
+			}
+			if i+n >= len(b) {
+				return
+			}
+			d.command(t, c, b[i+1:i+1+n])
+			i += n
+		default:
+			d.win().put(rune(c))
+		}
+	}
+}
+
+func (d *Decoder708) command(t time.Duration, c byte, params []byte) {
+	each := func(mask byte, f func(w *window)) {
+		for i := range d.windows {
+			if mask&(1<<uint(i)) != 0 {
+				f(&d.windows[i])
+			}
+		}
+		d.update(t)
+	}
+
+	switch {
+	case c <= 0x87:
+		d.current = int(c - 0x80)
+	case c == 0x88:
+		each(params[0], (*window).clear)
+	case c == 0x89:
+		each(params[0], func(w *window) { w.visible = true })
+	case c == 0x8A:
+		each(params[0], func(w *window) { w.visible = false })
+	case c == 0x8B:
+		each(params[0], func(w *window) { w.visible = !w.visible })
+	case c == 0x8C:
+		each(params[0], func(w *window) { *w = window{} })
+	case c == 0x8F:
+		d.windows = [8]window{}
+		d.update(t)
+	case c == 0x92:
+		w := d.win()
+		w.row, w.col = int(params[0]&0x0F), int(params[1]&0x3F)
+		if w.row >= rows {
+			w.row = rows - 1
+		}
+	case c >= 0x98:
+		d.current = int(c - 0x98)
+		w := d.win()
+		if !w.defined {
+			w.clear()
+		}
+		w.defined = true
+		w.visible = params[0]&0x20 != 0
+		w.rows = int(params[3]&0x0F) + 1
+		if w.rows > rows {
+			w.rows = rows
+		}
+		d.update(t)
+	}
+}
+
+func (d *Decoder708) update(t time.Duration) {
+	var text []string
+	for i := range d.windows {
+		if w := &d.windows[i]; w.defined && w.visible {
+			if s := w.String(); s != "" {
+				text = append(text, s)
+			}
+		}
+	}
+	d.builder.show(t, strings.Join(text, "\n"))
+}
+
+func (d *Decoder708) Cues() []Cue {
+	d.update(d.lastTime)
+	d.builder.flush(d.lastTime)
+	return d.builder.cues
+}
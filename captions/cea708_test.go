@@ -0,0 +1,63 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package captions
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// df0 defines window 0, visible, with two rows.
+var df0 = []byte{0x98, 0x20, 0, 0, 0x01, 0, 0}
+
+type step708 struct {
+	at    time.Duration
+	block []byte
+}
+
+func TestDecoder708(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step708
+		cues  []Cue
+	}{
+		{"define and clear", []step708{
+			{0, append(append([]byte{}, df0...), 'H', 'I', 0x89, 0x01)},
+			{2 * time.Second, []byte{0x88, 0x01}},
+		}, []Cue{{0, 2 * time.Second, "HI"}}},
+		// DLC takes no parameters, the text after it is kept.
+		{"delay cancel", []step708{
+			{0, append(append([]byte{}, df0...), 'H', 'I', 0x8E, 'Y', 0x89, 0x01)},
+			{2 * time.Second, []byte{0x8C, 0x01}},
+		}, []Cue{{0, 2 * time.Second, "HIY"}}},
+		{"pen location", []step708{
+			{0, append(append([]byte{}, df0...), 'A', 0x92, 0x01, 0x00, 'B', 0x89, 0x01)},
+			{time.Second, []byte{0x8A, 0x01}},
+		}, []Cue{{0, time.Second, "A\nB"}}},
+	}
+
+	for _, tt := range tests {
+		d := NewDecoder708()
+		for _, s := range tt.steps {
+			d.lastTime = s.at
+			d.serviceBlock(s.at, s.block)
+		}
+		if cues := d.Cues(); !reflect.DeepEqual(cues, tt.cues) {
+			t.Errorf("%s: got %q, want %q", tt.name, cues, tt.cues)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package captions
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+)
+
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Longest time a caption stays on screen without being cleared.
+const maxCueDuration = 10 * time.Second
+
+// cueBuilder turns changes to the text on screen into cues.
+type cueBuilder struct {
+	text  string
+	start time.Duration
+	cues  []Cue
+}
+
+func (b *cueBuilder) show(t time.Duration, text string) {
+	if text == b.text {
+		return
+	}
+	b.flush(t)
+	b.text, b.start = text, t
+}
+
+func (b *cueBuilder) flush(t time.Duration) {
+	if b.text == "" {
+		return
+	}
+	if t-b.start > maxCueDuration {
+		t = b.start + maxCueDuration
+	}
+	if t > b.start {
+		b.cues = append(b.cues, Cue{Start: b.start, End: t, Text: b.text})
+	}
+	b.text = ""
+}
+
+type Format int
+
+const (
+	SRT Format = iota
+	WebVTT
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "srt":
+		return SRT, nil
+	case "vtt", "webvtt":
+		return WebVTT, nil
+	}
+	return 0, fmt.Errorf("Unknown subtitle format %q", s)
+}
+
+func (f Format) Ext() string {
+	if f == WebVTT {
+		return ".vtt"
+	}
+	return ".srt"
+}
+
+func formatTime(d time.Duration, sep string) string {
+	d = d.Round(time.Millisecond)
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, sep, d/time.Millisecond)
+}
+
+func Write(w io.Writer, cues []Cue, format Format) error {
+	bw := bufio.NewWriter(w)
+
+	if format == WebVTT {
+		fmt.Fprint(bw, "WEBVTT\n\n")
+	}
+	for i, c := range cues {
+		text := strings.Replace(c.Text, "\n\n", "\n", -1)
+		switch format {
+		case SRT:
+			fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1, formatTime(c.Start, ","), formatTime(c.End, ","), text)
+		case WebVTT:
+			text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+			fmt.Fprintf(bw, "%s --> %s\n%s\n\n", formatTime(c.Start, "."), formatTime(c.End, "."), text)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func WriteFile(filename string, cues []Cue, format Format) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = Write(file, cues, format)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Decode decodes the captions in data, preferring the CEA-608 captions
+// and falling back to CEA-708 service 1 if there are none.
+func Decode(data []mpegts.CCData) []Cue {
+	d608, d708 := NewDecoder608(), NewDecoder708()
+
+	for _, cc := range data {
+		switch cc.Type {
+		case 0:
+			d608.Decode(cc.Time, cc.Data)
+		case 2, 3:
+			d708.Decode(cc.Time, cc.Type, cc.Data)
+		}
+	}
+
+	if cues := d608.Cues(); len(cues) > 0 {
+		return cues
+	}
+	return d708.Cues()
+}
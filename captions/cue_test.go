@@ -0,0 +1,70 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package captions
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	cues := []Cue{
+		{Start: time.Second, End: 2*time.Second + 500*time.Millisecond, Text: "Tom & Jerry"},
+		{Start: time.Hour + 61*time.Second, End: time.Hour + 62*time.Second, Text: "<music>\n\nLa la"},
+	}
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{SRT, "1\n00:00:01,000 --> 00:00:02,500\nTom & Jerry\n\n" +
+			"2\n01:01:01,000 --> 01:01:02,000\n<music>\nLa la\n\n"},
+		{WebVTT, "WEBVTT\n\n" +
+			"00:00:01.000 --> 00:00:02.500\nTom &amp; Jerry\n\n" +
+			"01:01:01.000 --> 01:01:02.000\n&lt;music&gt;\nLa la\n\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, cues, tt.format); err != nil {
+			t.Errorf("Write(%s): %v", tt.format.Ext(), err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Write(%s) = %q, want %q", tt.format.Ext(), got, tt.want)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in  string
+		ext string
+		ok  bool
+	}{
+		{"srt", ".srt", true},
+		{"vtt", ".vtt", true},
+		{"webvtt", ".vtt", true},
+		{"ass", "", false},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseFormat(tt.in)
+		if (err == nil) != tt.ok || tt.ok && f.Ext() != tt.ext {
+			t.Errorf("ParseFormat(%q) = %s %v", tt.in, f.Ext(), err)
+		}
+	}
+}
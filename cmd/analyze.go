@@ -0,0 +1,100 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/cobra"
+)
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze FILE...",
+	Short: "Check the reception quality of recordings",
+	Long:  `Analyze the transport stream of each recording and report errors per PID.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run:   analyzeMain,
+}
+
+var analyzeJSON = false
+
+func init() {
+	rootCmd.AddCommand(analyzeCmd)
+
+	analyzeCmd.Flags().BoolVarP(&analyzeJSON, "json", "", false, "Print the report as JSON")
+}
+
+type analyzeReport struct {
+	Filename  string
+	Health    int
+	NullRatio float64
+	*mpegts.Report
+}
+
+func printAnalyzeReport(filename string, report *mpegts.Report) {
+	fmt.Printf("%v:\n", filename)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "PID\tPackets\tTEI\tCC errors\tPCRs\tPCR disc.\tMax PCR interval\tMax PCR jitter\t")
+	for _, p := range report.SortedPids() {
+		fmt.Fprintf(w, "0x%04X\t%d\t%d\t%d\t%d\t%d\t%v\t%v\t\n", p.Pid, p.Packets, p.TransportErrors,
+			p.ContinuityErrors, p.PCRs, p.PCRDiscontinuities, p.MaxPCRInterval, p.MaxPCRJitter)
+	}
+	w.Flush()
+
+	fmt.Printf("Packets: %d, sync errors: %d, null packets: %.2f%%\n",
+		report.Packets, report.SyncErrors, report.NullRatio()*100)
+	fmt.Printf("Duration: %v\n", report.Duration)
+	fmt.Printf("Health: %d/100\n", report.Health())
+}
+
+func analyzeMain(cmd *cobra.Command, args []string) {
+	var reports []analyzeReport
+
+	failed := false
+	for _, filename := range args {
+		report, err := mpegts.AnalyzeFile(filename)
+		if err != nil {
+			log.Printf("Unable to analyze %q: %v\n", filename, err)
+			failed = true
+			continue
+		}
+
+		if analyzeJSON {
+			reports = append(reports, analyzeReport{filename, report.Health(), report.NullRatio(), report})
+		} else {
+			printAnalyzeReport(filename, report)
+		}
+	}
+
+	if analyzeJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
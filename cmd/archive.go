@@ -16,19 +16,27 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
-	"syscall"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/saintdev/hdhrdvrutil/captions"
+	"github.com/saintdev/hdhrdvrutil/edl"
 	"github.com/saintdev/hdhrdvrutil/hdhomerun"
 	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
 
 	"github.com/gosimple/slug"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var archiveCmd = &cobra.Command{
@@ -40,121 +48,353 @@ var archiveCmd = &cobra.Command{
 }
 
 var (
-	delete  = false
-	srcDir  = ""
-	destDir = ""
+	deleteRecordings = false
+	dedupe           = false
+	archiveFormat    = "mkv"
+	srcDir           = ""
+	destDir          = ""
 )
 
 func init() {
 	rootCmd.AddCommand(archiveCmd)
 
-	archiveCmd.Flags().BoolVarP(&delete, "delete", "", false, "Delete recordings after archiving")
+	archiveCmd.Flags().BoolVarP(&deleteRecordings, "delete", "", false, "Delete recordings after archiving")
+	archiveCmd.Flags().BoolVarP(&dedupe, "dedupe", "", false, "Only archive the best copy of duplicate recordings, including copies already archived")
+	archiveCmd.Flags().StringVarP(&archiveFormat, "format", "", archiveFormat, "Archive format (mkv, ts)")
+	archiveCmd.Flags().StringSliceP("audio-languages", "", []string{defaultAudioLanguages}, "Preferred audio languages, in order")
+
+	viper.BindPFlag("audio-languages", archiveCmd.Flags().Lookup("audio-languages"))
 }
 
-func validateDirs(args []string) {
-	var err error
-	srcDir, err = filepath.Abs(args[0])
+func absDir(dir string) string {
+	abs, err := filepath.Abs(dir)
 	if err != nil {
-		log.Fatalf("Unable to construct absolute srcdir path %q: %v", args[0], err)
+		log.Fatalf("Unable to construct absolute path %q: %v", dir, err)
 	}
-	if _, err := os.Stat(srcDir); os.IsNotExist(err) {
-		log.Fatalf("Path does not exist %q", srcDir)
+	if _, err := os.Stat(abs); os.IsNotExist(err) {
+		log.Fatalf("Path does not exist %q", abs)
 	}
 
-	destDir, err = filepath.Abs(args[1])
-	if err != nil {
-		log.Fatalf("Unable to construct absolute destdir path %q: %v", args[1], err)
-	}
-	if _, err := os.Stat(destDir); os.IsNotExist(err) {
-		log.Fatalf("Path does not exist %q", destDir)
-	}
+	return abs
 }
 
-func archiveMain(cmd *cobra.Command, args []string) {
-	var recordings []*hdhomerun.Recording
+func validateDirs(args []string) {
+	srcDir = absDir(args[0])
+	destDir = absDir(args[1])
+}
 
+func archiveMain(cmd *cobra.Command, args []string) {
 	validateDirs(args)
 
+	if archiveFormat != "mkv" && archiveFormat != "ts" {
+		log.Fatalf("Unknown archive format %q", archiveFormat)
+	}
+	// Fail early on an invalid name template or codec policy.
+	archiveTemplate = nameTemplate()
+	validateCodecPolicies()
+
+	var transcriptFile string
+	if indexTranscripts {
+		archiveTranscripts, transcriptFile = loadTranscriptIndex()
+	}
+
 	log.Printf("Source: %q\n", srcDir)
 	log.Printf("Destination: %q\n", destDir)
 
 	dvrClient := hdhomerun.NewClient(nil)
+	recordings := fetchRecordings(dvrClient)
 
-	devices, err := dvrClient.Devices.Discover()
-	if err != nil {
-		log.Fatalf("Unable to discover devices: %v\n", err)
+	// Files the DVR doesn't list are archived from the metadata recovered
+	// from them, but never deleted.
+	unlisted := scanRecordings(dvrClient, srcDir, recordings)
+	for _, r := range unlisted {
+		log.Printf("Archiving unlisted file %q as %q\n", *r.LocalFilename, *r.Title)
 	}
+	recordings = append(recordings, unlisted...)
 
-	for _, device := range devices {
-		if !device.IsRecordEngine() {
-			continue
+	groups := map[*hdhomerun.Recording][]*hdhomerun.Recording{}
+	joined := map[*hdhomerun.Recording]bool{}
+	for _, g := range splitGroups(recordings) {
+		groups[g[0]] = g
+		for _, r := range g[1:] {
+			joined[r] = true
+		}
+	}
+
+	skip := map[*hdhomerun.Recording]bool{}
+	if dedupe {
+		// Later parts of a split recording aren't duplicates of the first.
+		var candidates []*hdhomerun.Recording
+		for _, r := range recordings {
+			if !joined[r] {
+				candidates = append(candidates, r)
+			}
+		}
+		// The better copy replaces worse ones already in the archive.
+		for _, r := range findDuplicates(candidates, archivedCopies(destDir)) {
+			if r.sidecar == "" {
+				skip[r.Recording] = true
+			} else if deleteRecordings {
+				log.Printf("Removing archived duplicate %q\n", *r.LocalFilename)
+				removeArchived(r)
+			}
 		}
+	}
 
-		recordings, err = dvrClient.Devices.RecordedFiles(device)
-		if err != nil {
-			log.Printf("Failed to parse `recorded_files.json` for device at %q: %v\n", *device.BaseURL, err)
+	var failed []string
+	kept := map[*hdhomerun.Recording]bool{}
+	for _, r := range recordings {
+		if r.LocalFilename == nil {
 			continue
 		}
+
+		archived := true
+		if skip[r] {
+			log.Printf("Skipping duplicate %q\n", *r.LocalFilename)
+		} else if group, ok := groups[r]; ok {
+			if archived = archiveJoined(group, destDir); !archived {
+				for _, p := range group {
+					kept[p] = true
+				}
+			}
+		} else if !joined[r] {
+			archived = archiveRecording(r, destDir, nil)
+		}
+		if !archived {
+			kept[r] = true
+			failed = append(failed, *r.LocalFilename)
+		}
 	}
 
-	if len(recordings) == 0 {
-		log.Fatalln("No recordings found!")
+	if archiveTranscripts != nil {
+		if err := archiveTranscripts.Save(transcriptFile); err != nil {
+			log.Printf("Unable to save transcript index %q: %v\n", transcriptFile, err)
+		}
 	}
 
-	if err = dvrClient.Recordings.ScanRecordingsDir(srcDir, recordings); err != nil {
-		log.Fatalf("Error scanning recordings in %q: %v\n", srcDir, err)
+	if deleteRecordings {
+		for _, r := range recordings {
+			if r.LocalFilename == nil || r.CmdURL == nil || kept[r] {
+				continue
+			}
+			if err := dvrClient.Recordings.Delete(r, false); err != nil {
+				log.Printf("Failed to delete recording %q: %v\n", *r.LocalFilename, err)
+			}
+		}
 	}
 
-	for _, r := range recordings {
-		if r.Filename == nil {
-			continue
+	if len(failed) > 0 {
+		log.Printf("%d recordings were not archived:\n", len(failed))
+		for _, name := range failed {
+			log.Printf("  %s\n", name)
 		}
+	}
+}
 
-		copyToMkv(r, destDir)
+// Largest difference between the duration of a recording and its archive
+// that isn't reported.
+const durationTolerance = time.Second
 
-		if delete {
-			if err = dvrClient.Recordings.Delete(r, false); err != nil {
-				log.Printf("Failed to delete recording %q: %v\n", r.Filename, err)
-			}
-		}
+type archiveResult struct {
+	Source           string
+	Output           string
+	ProgramID        *string
+	Duration         time.Duration
+	ArchivedDuration time.Duration
+	Streams          []*mpegts.PidStats
+	// The recording and its video format, to find duplicates in the archive.
+	Recording *hdhomerun.Recording `json:",omitempty"`
+	Video     *mpegts.VideoInfo    `json:",omitempty"`
+}
+
+func writeArchiveResult(result *archiveResult) error {
+	jsonBuf, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
 	}
+
+	name := strings.TrimSuffix(result.Output, filepath.Ext(result.Output)) + ".json"
+
+	return ioutil.WriteFile(name, jsonBuf, 0644)
 }
 
-func copyToMkv(f *hdhomerun.Recording, destdir string) {
+// archiveName returns the file name of the archive of f, without extension.
+func archiveName(f *hdhomerun.Recording, video *mpegts.VideoInfo) string {
 	var filename string
 
-	mkvcmd := mkvmerge.New()
-	mkvcmd.SetInput(*f.Filename)
-	if f.EpisodeTitle == nil {
+	if f.EpisodeTitle == nil || f.IsMovie() {
 		filename = fmt.Sprintf("%s", *f.Title)
-	} else if f.EpisodeString == nil {
+	} else if len(f.Episodes) == 0 {
 		filename = fmt.Sprintf("%s", *f.EpisodeTitle)
+	} else if len(f.Episodes) > 1 {
+		filename = fmt.Sprintf("%02d%02d-%02d-%s", f.Season, f.Episode, f.Episodes[len(f.Episodes)-1], *f.EpisodeTitle)
 	} else {
 		filename = fmt.Sprintf("%02d%02d-%s", f.Season, f.Episode, *f.EpisodeTitle)
 	}
-	mkvcmd.SetOutput(path.Join(destdir, fmt.Sprintf("%s.mkv", slug.Make(filename))))
 
-	if f.EpisodeString != nil {
+	return templateName(f, slug.Make(filename), video)
+}
+
+// archiveRecording archives f in the selected format. gaps are marked as
+// chapters where the format allows. Recordings with streams mkvmerge can't
+// handle are archived according to the codec policy. It returns false if the
+// recording was skipped or archiving failed.
+func archiveRecording(f *hdhomerun.Recording, destdir string, gaps []mpegts.Gap) bool {
+	if archiveFormat == "ts" {
+		return copyToTS(f, destdir)
+	}
+
+	policy, codecs := recordingPolicy(*f.LocalFilename)
+	if policy != policyMkvMerge {
+		log.Printf("%q has %s, using policy %q\n", *f.LocalFilename, strings.Join(codecs, ", "), policy)
+	}
+
+	switch policy {
+	case policySkip:
+		log.Printf("Skipping %q\n", *f.LocalFilename)
+		return false
+	case policyCopy:
+		return copyUnchanged(f, destdir)
+	case policyRemux:
+		return copyToTS(f, destdir)
+	case policyFFmpeg:
+		return copyWithFFmpeg(f, destdir)
+	}
+
+	return copyToMkv(f, destdir, gaps)
+}
+
+func copyToMkv(f *hdhomerun.Recording, destdir string, gaps []mpegts.Gap) bool {
+	movie := f.IsMovie()
+
+	video := readVideoInfo(*f.LocalFilename)
+
+	mkvcmd := mkvmerge.New()
+	mkvcmd.SetInput(*f.LocalFilename)
+	output := path.Join(destdir, archiveName(f, video)+".mkv")
+	mkvcmd.SetOutput(output)
+
+	if len(f.Episodes) > 0 && !movie {
 		mkvcmd.SetEpisodeTag(f.Episode)
 		mkvcmd.SetSeasonTag(f.Season)
 	}
-	if f.EpisodeTitle != nil {
+	if f.EpisodeTitle != nil && !movie {
 		mkvcmd.SetSubTitleTag(*f.EpisodeTitle)
 	}
 	if f.Synopsis != nil {
 		mkvcmd.SetSynopsisTag(*f.Synopsis)
 	}
 	mkvcmd.SetTitleTag(*f.Title)
+	if video != nil {
+		setVideoTags(mkvcmd, video)
+	}
 
-	mkvcmd.Quiet = true
+	setAudioTracks(mkvcmd, *f.LocalFilename)
 
-	if err := mkvcmd.Exec(); err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			waitStatus := exitError.Sys().(syscall.WaitStatus)
-			if waitStatus.ExitStatus() != 1 {
-				log.Fatalf("Failed to exec mkvmerge: %v", err)
-			}
+	result := &archiveResult{
+		Source:    *f.LocalFilename,
+		Output:    output,
+		ProgramID: f.ProgramID,
+		Recording: f,
+		Video:     video,
+	}
+
+	report, err := mpegts.AnalyzeFile(*f.LocalFilename)
+	if err != nil {
+		log.Printf("Unable to analyze %q: %v\n", *f.LocalFilename, err)
+	} else {
+		result.Duration = report.Duration
+		result.Streams = report.SortedPids()
+	}
+
+	var breaks []edl.Entry
+	if markAds && result.Duration > 0 {
+		breaks = scte35Breaks(*f.LocalFilename, result.Duration)
+	}
+	if detectCommercials {
+		breaks = mergeBreaks(append(breaks, comskipBreaks(*f.LocalFilename, result.Duration)...))
+	}
+
+	expected := result.Duration
+	parts := []mkvmerge.Part{{}}
+	if trimPadding {
+		if part, ok := programPart(f, streamStart(f), result.Duration); ok {
+			parts[0] = part
+		}
+	}
+	if cuts := cutList(*f.LocalFilename, breaks); len(cuts) > 0 {
+		if kept := cutParts(parts[0], result.Duration, cuts); len(kept) > 0 {
+			parts = kept
+		} else {
+			log.Printf("Nothing left of %q after removing cuts, keeping it whole\n", *f.LocalFilename)
+		}
+	}
+	if len(parts) != 1 || parts[0] != (mkvmerge.Part{}) {
+		mkvcmd.SetSplitParts(parts)
+		expected = partsDuration(parts, result.Duration)
+		breaks = shiftBreaks(breaks, parts)
+	}
+
+	var chapters []mkvmerge.ChapterMark
+	if len(breaks) > 0 {
+		chapters = breakChapters(breaks)
+		writeEDL(output, breaks)
+	}
+	if marks := gapChapters(gaps, parts); len(marks) > 0 {
+		if len(chapters) == 0 {
+			chapters = append(chapters, mkvmerge.ChapterMark{Start: 0, Name: "Part 1"})
+		}
+		chapters = append(chapters, marks...)
+		sort.SliceStable(chapters, func(i, j int) bool {
+			return chapters[i].Start < chapters[j].Start
+		})
+	}
+	if len(chapters) > 0 {
+		mkvcmd.SetChapters(chapters)
+	}
+
+	var cues []captions.Cue
+	if extractCaptions || indexTranscripts {
+		cues = recordingCaptions(*f.LocalFilename)
+	}
+	if extractCaptions {
+		if subtitles := addCaptions(mkvcmd, *f.LocalFilename, cues); subtitles != "" {
+			defer os.Remove(subtitles)
 		}
 	}
+
+	mkvcmd.Quiet = true
+
+	err = mkvcmd.Exec()
 	defer mkvcmd.Close()
+	if !mkvmerge.Succeeded(err) {
+		if _, ok := err.(*exec.ExitError); !ok {
+			log.Printf("Failed to exec mkvmerge: %v\n", err)
+			return false
+		}
+
+		log.Printf("mkvmerge failed on %q: %v, retrying with a repaired copy\n", *f.LocalFilename, err)
+		if err = execRepaired(mkvcmd, *f.LocalFilename, destdir); err != nil {
+			log.Printf("Failed to archive %q: %v\n", *f.LocalFilename, err)
+			return false
+		}
+	}
+
+	if result.ArchivedDuration, err = mkvmerge.Duration(output); err != nil {
+		log.Printf("Unable to read duration of %q: %v\n", output, err)
+	} else if expected > 0 {
+		if diff := result.ArchivedDuration - expected; diff > durationTolerance || diff < -durationTolerance {
+			log.Printf("Duration of %q is %v, expected %v\n", output, result.ArchivedDuration, expected)
+		}
+	}
+
+	if err = writeArchiveResult(result); err != nil {
+		log.Printf("Unable to write archive result for %q: %v\n", output, err)
+	}
+
+	if archiveTranscripts != nil {
+		archiveTranscripts.Add(transcriptDocument(f, output, cues, parts))
+	}
+
+	return true
 }
@@ -0,0 +1,54 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+)
+
+func TestArchiveName(t *testing.T) {
+	title, episodeTitle, partTitle, category := "The Show", "The Pilot", "Part One", "movie"
+
+	tests := []struct {
+		name      string
+		recording hdhomerun.Recording
+		want      string
+	}{
+		{"title only", hdhomerun.Recording{Title: &title}, "the-show"},
+		{"episode", hdhomerun.Recording{Title: &title, EpisodeTitle: &episodeTitle, Season: 1, Episode: 2, Episodes: []int{2}}, "0102-the-pilot"},
+		{"double episode", hdhomerun.Recording{Title: &title, EpisodeTitle: &episodeTitle, Season: 1, Episode: 2, Episodes: []int{2, 3}}, "0102-03-the-pilot"},
+		// An episode string that isn't a number doesn't give an episode.
+		{"unnumbered episode", hdhomerun.Recording{Title: &title, EpisodeTitle: &partTitle, EpisodeString: &partTitle}, "part-one"},
+		{"movie", hdhomerun.Recording{Title: &title, EpisodeTitle: &episodeTitle, Category: &category}, "the-show"},
+	}
+
+	for _, tt := range tests {
+		if got := archiveName(&tt.recording, nil); got != tt.want {
+			t.Errorf("%s: archiveName() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	archiveTemplate = template.Must(template.New("name").Parse("{{.Name}}-{{.Resolution}}"))
+	defer func() { archiveTemplate = nil }()
+	video := &mpegts.VideoInfo{Width: 1920, Height: 1080, Interlaced: true}
+	if got, want := archiveName(&tests[1].recording, video), "0102-the-pilot-1080i"; got != want {
+		t.Errorf("archiveName() with a template = %q, want %q", got, want)
+	}
+}
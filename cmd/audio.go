@@ -0,0 +1,149 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"log"
+	"strings"
+
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/viper"
+)
+
+// ISO 639 audio_type values.
+const (
+	audioCleanEffects     = 1
+	audioHearingImpaired  = 2
+	audioVisualImpaired   = 3
+	defaultAudioLanguages = "eng"
+)
+
+var languageNames = map[string]string{
+	"eng": "English",
+	"spa": "Spanish",
+	"fre": "French",
+	"fra": "French",
+	"ger": "German",
+	"deu": "German",
+	"ita": "Italian",
+	"por": "Portuguese",
+	"chi": "Chinese",
+	"zho": "Chinese",
+	"kor": "Korean",
+	"jpn": "Japanese",
+	"vie": "Vietnamese",
+}
+
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimRight(lang, "\x00 "))
+	if len(lang) != 3 {
+		return "und"
+	}
+	return lang
+}
+
+func audioTrackName(s *mpegts.Stream) string {
+	lang := normalizeLanguage(s.Language)
+	name, ok := languageNames[lang]
+	if !ok {
+		name = lang
+	}
+	if lang == "und" {
+		name = "Unknown"
+	}
+
+	switch s.AudioType {
+	case audioVisualImpaired:
+		name += " (Descriptive)"
+	case audioHearingImpaired:
+		name += " (Hearing impaired)"
+	case audioCleanEffects:
+		name += " (Clean effects)"
+	}
+
+	return name + " " + s.Codec
+}
+
+// audioRank orders audio streams by the preferred language list, regular
+// audio before descriptive or other special tracks.
+func audioRank(s *mpegts.Stream, preferred []string) int {
+	rank := len(preferred)
+	lang := normalizeLanguage(s.Language)
+	for i, p := range preferred {
+		if normalizeLanguage(p) == lang {
+			rank = i
+			break
+		}
+	}
+
+	rank *= 2
+	if s.AudioType != 0 {
+		rank++
+	}
+
+	return rank
+}
+
+// setAudioTracks labels the audio tracks of the recording with their language
+// and marks the one best matching the preferred languages as default.
+func setAudioTracks(mkvcmd *mkvmerge.MkvMerge, filename string) {
+	programs, err := mpegts.ReadProgramsFile(filename)
+	if err != nil {
+		log.Printf("Unable to read PMT from %q: %v\n", filename, err)
+		return
+	}
+
+	tracks, err := mkvmerge.Identify(filename)
+	if err != nil {
+		log.Printf("Unable to identify tracks in %q: %v\n", filename, err)
+		return
+	}
+
+	labelAudioTracks(mkvcmd, programs, tracks, viper.GetStringSlice("audio-languages"))
+}
+
+// labelAudioTracks matches the tracks mkvmerge found to the streams of the
+// programs by PID, and labels the audio tracks.
+func labelAudioTracks(mkvcmd *mkvmerge.MkvMerge, programs []*mpegts.Program, tracks []mkvmerge.Track, preferred []string) {
+	streams := map[int]*mpegts.Stream{}
+	for _, p := range programs {
+		for _, s := range p.Streams {
+			streams[int(s.Pid)] = s
+		}
+	}
+
+	best, bestRank := -1, 0
+	for _, t := range tracks {
+		s, ok := streams[t.Pid()]
+		if t.Type != "audio" || !ok {
+			continue
+		}
+
+		mkvcmd.SetTrackLanguage(t.ID, normalizeLanguage(s.Language))
+		mkvcmd.SetTrackName(t.ID, audioTrackName(s))
+		mkvcmd.SetDefaultTrack(t.ID, false)
+
+		if rank := audioRank(s, preferred); best < 0 || rank < bestRank {
+			best, bestRank = t.ID, rank
+		}
+	}
+
+	if best >= 0 {
+		mkvcmd.SetDefaultTrack(best, true)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+)
+
+// mkvmerge -J of a recording, its track IDs don't follow the PIDs.
+const identifyJSON = `{
+  "container": {
+    "properties": {"duration": 3600000000000},
+    "recognized": true,
+    "supported": true,
+    "type": "MPEG transport stream"
+  },
+  "tracks": [
+    {"codec": "MPEG-1/2", "id": 0, "properties": {"language": "und", "number": 49, "pixel_dimensions": "1920x1080"}, "type": "video"},
+    {"codec": "AC-3", "id": 1, "properties": {"audio_channels": 2, "language": "spa", "number": 53}, "type": "audio"},
+    {"codec": "AC-3", "id": 2, "properties": {"audio_channels": 6, "language": "eng", "number": 52}, "type": "audio"},
+    {"codec": "AC-3", "id": 3, "properties": {"audio_channels": 2, "language": "eng", "number": 54}, "type": "audio"}
+  ]
+}`
+
+// fakeMkvMerge puts an mkvmerge on the PATH that prints identifyJSON when
+// identifying and otherwise writes its arguments to the args file in dir.
+func fakeMkvMerge(t *testing.T, dir string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, "identify.json"), []byte(identifyJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf("#!/bin/sh\nif [ \"$1\" = --identification-format ]; then cat %q; exit 0; fi\nprintf '%%s\\n' \"$@\" > %q\n",
+		filepath.Join(dir, "identify.json"), filepath.Join(dir, "args"))
+	if err := ioutil.WriteFile(filepath.Join(dir, "mkvmerge"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLabelAudioTracks(t *testing.T) {
+	programs := []*mpegts.Program{{Number: 1, Streams: []*mpegts.Stream{
+		{Pid: 49, Type: mpegts.StreamMPEG2Video, Codec: "MPEG-2"},
+		{Pid: 52, Codec: "AC-3", Language: "eng"},
+		{Pid: 53, Codec: "AC-3", Language: "spa"},
+		{Pid: 54, Codec: "AC-3", Language: "eng", AudioType: audioVisualImpaired},
+		// Not found by mkvmerge.
+		{Pid: 55, Codec: "AC-3", Language: "fre"},
+	}}}
+
+	labels := []string{
+		"--language", "1:spa", "--track-name", "1:Spanish AC-3",
+		"--language", "2:eng", "--track-name", "2:English AC-3",
+		"--language", "3:eng", "--track-name", "3:English (Descriptive) AC-3",
+	}
+	withDefault := func(def int) []string {
+		var args []string
+		for id := 1; id <= 3; id++ {
+			flag := "no"
+			if id == def {
+				flag = "yes"
+			}
+			args = append(args, labels[(id-1)*4:id*4]...)
+			args = append(args, "--default-track", fmt.Sprintf("%d:%s", id, flag))
+		}
+		return args
+	}
+
+	tests := []struct {
+		name      string
+		programs  []*mpegts.Program
+		preferred []string
+		args      []string
+	}{
+		{"english", programs, []string{"eng"}, withDefault(2)},
+		{"spanish first", programs, []string{"spa", "eng"}, withDefault(1)},
+		{"no preferred language", programs, []string{"fre"}, withDefault(1)},
+		{"no PMT", nil, []string{"eng"}, nil},
+	}
+
+	dir, err := ioutil.TempDir("", "audio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	fakeMkvMerge(t, dir)
+
+	input := filepath.Join(dir, "in.ts")
+	tracks, err := mkvmerge.Identify(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		mkvcmd := mkvmerge.New()
+		mkvcmd.SetInput(input)
+		mkvcmd.SetOutput(filepath.Join(dir, "out.mkv"))
+		labelAudioTracks(mkvcmd, tt.programs, tracks, tt.preferred)
+		if err := mkvcmd.Exec(); err != nil {
+			t.Fatal(err)
+		}
+		mkvcmd.Close()
+
+		out, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Leave out --output, the output and --quiet, and the input.
+		args := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		args = args[3 : len(args)-1]
+		if len(args) == 0 {
+			args = nil
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: got %q, want %q", tt.name, args, tt.args)
+		}
+	}
+}
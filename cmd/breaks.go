@@ -0,0 +1,142 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/edl"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+)
+
+var (
+	markAds   = false
+	edlFormat = "kodi"
+)
+
+func init() {
+	archiveCmd.Flags().BoolVarP(&markAds, "scte35", "", false, "Mark SCTE-35 ad breaks as chapters")
+	archiveCmd.Flags().StringVarP(&edlFormat, "edl-format", "", edlFormat, "Format of EDL sidecar files (kodi, mplayer, none)")
+}
+
+func scte35Breaks(filename string, duration time.Duration) []edl.Entry {
+	adBreaks, err := mpegts.ReadAdBreaksFile(filename)
+	if err != nil {
+		log.Printf("Unable to read SCTE-35 cues from %q: %v\n", filename, err)
+		return nil
+	}
+
+	var breaks []edl.Entry
+	for _, b := range adBreaks {
+		end := b.End
+		if end == 0 || end > duration {
+			end = duration
+		}
+		if end > b.Start {
+			breaks = append(breaks, edl.Entry{Start: b.Start, End: end, Action: edl.Commercial})
+		}
+	}
+
+	return mergeBreaks(breaks)
+}
+
+// shiftBreaks moves breaks onto the timeline of the parts of the recording
+// that are kept, dropping those outside of them.
+func shiftBreaks(breaks []edl.Entry, parts []mkvmerge.Part) []edl.Entry {
+	var (
+		shifted []edl.Entry
+		offset  time.Duration
+	)
+	for _, p := range parts {
+		for _, b := range breaks {
+			if b.Start < p.Start {
+				b.Start = p.Start
+			}
+			if p.End > 0 && b.End > p.End {
+				b.End = p.End
+			}
+			if b.End <= b.Start {
+				continue
+			}
+
+			b.Start += offset - p.Start
+			b.End += offset - p.Start
+			shifted = append(shifted, b)
+		}
+		offset += p.End - p.Start
+	}
+
+	return mergeBreaks(shifted)
+}
+
+// mergeBreaks combines breaks from several sources, joining those that
+// overlap.
+func mergeBreaks(breaks []edl.Entry) []edl.Entry {
+	sort.Slice(breaks, func(i, j int) bool {
+		return breaks[i].Start < breaks[j].Start
+	})
+
+	var merged []edl.Entry
+	for _, b := range breaks {
+		if n := len(merged); n > 0 && b.Start <= merged[n-1].End {
+			if b.End > merged[n-1].End {
+				merged[n-1].End = b.End
+			}
+			continue
+		}
+		merged = append(merged, b)
+	}
+
+	return merged
+}
+
+// breakChapters turns breaks into alternating "Show" and "Commercial"
+// chapters.
+func breakChapters(breaks []edl.Entry) []mkvmerge.ChapterMark {
+	var chapters []mkvmerge.ChapterMark
+
+	if len(breaks) == 0 || breaks[0].Start > 0 {
+		chapters = append(chapters, mkvmerge.ChapterMark{Start: 0, Name: "Show"})
+	}
+	for _, b := range breaks {
+		chapters = append(chapters, mkvmerge.ChapterMark{Start: b.Start, Name: "Commercial"})
+		chapters = append(chapters, mkvmerge.ChapterMark{Start: b.End, Name: "Show"})
+	}
+
+	return chapters
+}
+
+func writeEDL(output string, breaks []edl.Entry) {
+	if edlFormat == "none" {
+		return
+	}
+
+	format, err := edl.ParseFormat(edlFormat)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	name := strings.TrimSuffix(output, filepath.Ext(output)) + ".edl"
+	if err = edl.WriteFile(name, breaks, format); err != nil {
+		log.Printf("Unable to write %q: %v\n", name, err)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/edl"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+)
+
+func commercial(start, end time.Duration) edl.Entry {
+	return edl.Entry{Start: start, End: end, Action: edl.Commercial}
+}
+
+func TestShiftBreaks(t *testing.T) {
+	const m = time.Minute
+
+	tests := []struct {
+		name    string
+		breaks  []edl.Entry
+		parts   []mkvmerge.Part
+		shifted []edl.Entry
+	}{
+		{"one part", []edl.Entry{commercial(10*m, 12*m)}, []mkvmerge.Part{part(0, 0)}, []edl.Entry{commercial(10*m, 12*m)}},
+		{"trimmed padding", []edl.Entry{commercial(0, 3*m), commercial(10*m, 12*m), commercial(30*m, 35*m)},
+			[]mkvmerge.Part{part(2*m, 32*m)},
+			[]edl.Entry{commercial(0, m), commercial(8*m, 10*m), commercial(28*m, 30*m)}},
+		{"across a cut", []edl.Entry{commercial(8*m, 16*m), commercial(20*m, 22*m)},
+			[]mkvmerge.Part{part(0, 10*m), part(15*m, 0)},
+			[]edl.Entry{commercial(8*m, 11*m), commercial(15*m, 17*m)}},
+		{"inside a cut", []edl.Entry{commercial(11*m, 14*m)},
+			[]mkvmerge.Part{part(0, 10*m), part(15*m, 0)}, nil},
+	}
+
+	for _, tt := range tests {
+		if shifted := shiftBreaks(tt.breaks, tt.parts); !reflect.DeepEqual(shifted, tt.shifted) {
+			t.Errorf("%s: got %v, want %v", tt.name, shifted, tt.shifted)
+		}
+	}
+}
+
+func TestMergeBreaks(t *testing.T) {
+	const m = time.Minute
+
+	tests := []struct {
+		breaks []edl.Entry
+		merged []edl.Entry
+	}{
+		{nil, nil},
+		{[]edl.Entry{commercial(20*m, 22*m), commercial(10*m, 12*m)}, []edl.Entry{commercial(10*m, 12*m), commercial(20*m, 22*m)}},
+		{[]edl.Entry{commercial(10*m, 15*m), commercial(12*m, 14*m), commercial(15*m, 18*m)}, []edl.Entry{commercial(10*m, 18*m)}},
+	}
+
+	for _, tt := range tests {
+		if merged := mergeBreaks(tt.breaks); !reflect.DeepEqual(merged, tt.merged) {
+			t.Errorf("mergeBreaks(%v) = %v, want %v", tt.breaks, merged, tt.merged)
+		}
+	}
+}
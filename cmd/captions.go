@@ -0,0 +1,107 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/saintdev/hdhrdvrutil/captions"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+)
+
+var (
+	extractCaptions = false
+	captionsFormat  = "srt"
+)
+
+func init() {
+	archiveCmd.Flags().BoolVarP(&extractCaptions, "captions", "", false, "Convert closed captions into a subtitle track")
+	archiveCmd.Flags().StringVarP(&captionsFormat, "captions-format", "", captionsFormat, "Format of the subtitle track (srt, vtt)")
+}
+
+// captionLanguage returns the language of the first caption service
+// announced for the video stream, defaulting to English.
+func captionLanguage(filename string) string {
+	programs, err := mpegts.ReadProgramsFile(filename)
+	if err != nil {
+		return "eng"
+	}
+
+	for _, p := range programs {
+		for _, s := range p.Streams {
+			if s.IsVideo() && len(s.Captions) > 0 {
+				if lang := normalizeLanguage(s.Captions[0].Language); lang != "und" {
+					return lang
+				}
+			}
+		}
+	}
+
+	return "eng"
+}
+
+// recordingCaptions decodes the closed captions of the recording.
+func recordingCaptions(filename string) []captions.Cue {
+	data, err := mpegts.ReadCaptionDataFile(filename)
+	if err != nil {
+		log.Printf("Unable to read captions from %q: %v\n", filename, err)
+		return nil
+	}
+
+	return captions.Decode(data)
+}
+
+// addCaptions writes cues to a temporary subtitle file and adds it to
+// mkvcmd. The caller removes the returned file once muxing is done.
+func addCaptions(mkvcmd *mkvmerge.MkvMerge, filename string, cues []captions.Cue) string {
+	format, err := captions.ParseFormat(captionsFormat)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+
+	if len(cues) == 0 {
+		return ""
+	}
+	file, err := ioutil.TempFile("", filepath.Base(os.Args[0])+"-*"+format.Ext())
+	if err != nil {
+		log.Printf("Unable to create subtitle file: %v\n", err)
+		return ""
+	}
+
+	err = captions.Write(file, cues, format)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("Unable to write subtitles to %q: %v\n", file.Name(), err)
+		os.Remove(file.Name())
+		return ""
+	}
+
+	lang := captionLanguage(filename)
+	name, ok := languageNames[lang]
+	if !ok {
+		name = lang
+	}
+	mkvcmd.AddSubtitles(file.Name(), lang, name+" (SDH)")
+
+	return file.Name()
+}
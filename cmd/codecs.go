@@ -0,0 +1,257 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/ffmpeg"
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/viper"
+)
+
+// What to do with a recording containing a stream mkvmerge can't handle.
+const (
+	policyMkvMerge = "mkvmerge"
+	policyFFmpeg   = "ffmpeg"
+	policyRemux    = "remux"
+	policyCopy     = "copy"
+	policySkip     = "skip"
+)
+
+// Policies in order of precedence, when streams of one recording call for
+// different policies.
+var policyOrder = []string{policySkip, policyCopy, policyRemux, policyFFmpeg, policyMkvMerge}
+
+var defaultCodecPolicy = map[string]string{
+	"AC-4": policyCopy,
+}
+
+// Audio codecs mkvmerge can read from a transport stream.
+var mkvmergeAudio = map[string]bool{
+	"MPEG-1 audio": true,
+	"MPEG-2 audio": true,
+	"AAC":          true,
+	"AAC LATM":     true,
+	"AC-3":         true,
+	"E-AC-3":       true,
+}
+
+func init() {
+	archiveCmd.Flags().StringToStringP("codec-policy", "", nil,
+		"Handling of recordings with the given codec, e.g. AC-4=copy (mkvmerge, ffmpeg, remux, copy, skip)")
+	archiveCmd.Flags().StringP("unsupported-policy", "", policyCopy,
+		"Handling of recordings with other codecs mkvmerge can't handle")
+
+	viper.BindPFlag("codec-policy", archiveCmd.Flags().Lookup("codec-policy"))
+	viper.BindPFlag("unsupported-policy", archiveCmd.Flags().Lookup("unsupported-policy"))
+}
+
+// validateCodecPolicies exits if any configured policy is unknown.
+func validateCodecPolicies() {
+	for c, p := range viper.GetStringMapString("codec-policy") {
+		if policyRank(strings.ToLower(p)) >= len(policyOrder) {
+			log.Fatalf("Unknown policy %q for %s", p, c)
+		}
+	}
+	if p := viper.GetString("unsupported-policy"); policyRank(strings.ToLower(p)) >= len(policyOrder) {
+		log.Fatalf("Unknown policy %q for unsupported codecs", p)
+	}
+}
+
+// mkvmergeSupports reports whether mkvmerge can handle the stream. Only audio
+// matters, mkvmerge reads all our video types and drops data streams.
+func mkvmergeSupports(s *mpegts.Stream) bool {
+	if s.IsAudio() {
+		return mkvmergeAudio[s.Codec]
+	}
+	return true
+}
+
+func codecPolicy(s *mpegts.Stream) string {
+	for c, p := range viper.GetStringMapString("codec-policy") {
+		if strings.EqualFold(c, s.Codec) {
+			return strings.ToLower(p)
+		}
+	}
+	if p, ok := defaultCodecPolicy[s.Codec]; ok {
+		return p
+	}
+	if mkvmergeSupports(s) {
+		return policyMkvMerge
+	}
+	return strings.ToLower(viper.GetString("unsupported-policy"))
+}
+
+func policyRank(policy string) int {
+	for i, p := range policyOrder {
+		if p == policy {
+			return i
+		}
+	}
+	return len(policyOrder)
+}
+
+// recordingPolicy picks the policy for a recording from the codecs of its
+// streams, with the codecs that called for it.
+func recordingPolicy(filename string) (string, []string) {
+	programs, err := mpegts.ReadProgramsFile(filename)
+	if err != nil {
+		log.Printf("Unable to read PMT from %q: %v\n", filename, err)
+		return policyMkvMerge, nil
+	}
+
+	return programsPolicy(programs)
+}
+
+func programsPolicy(programs []*mpegts.Program) (string, []string) {
+	policy := policyMkvMerge
+	var codecs []string
+	for _, p := range programs {
+		for _, s := range p.Streams {
+			sp := codecPolicy(s)
+			if sp == policyMkvMerge {
+				continue
+			}
+			codecs = append(codecs, fmt.Sprintf("%s (PID 0x%04X)", s.Codec, s.Pid))
+			if policyRank(sp) < policyRank(policy) {
+				policy = sp
+			}
+		}
+	}
+
+	return policy, codecs
+}
+
+// analyzeSource starts the archive result of f, with the duration and
+// streams of the recording.
+func analyzeSource(f *hdhomerun.Recording, output string) *archiveResult {
+	result := &archiveResult{
+		Source:    *f.LocalFilename,
+		Output:    output,
+		ProgramID: f.ProgramID,
+		Recording: f,
+		Video:     readVideoInfo(*f.LocalFilename),
+	}
+
+	if report, err := mpegts.AnalyzeFile(*f.LocalFilename); err != nil {
+		log.Printf("Unable to analyze %q: %v\n", *f.LocalFilename, err)
+	} else {
+		result.Duration = report.Duration
+		result.Streams = report.SortedPids()
+	}
+
+	return result
+}
+
+// checkArchivedDuration records the duration of the archive and warns if it
+// is off from the recording.
+func checkArchivedDuration(result *archiveResult, duration time.Duration, err error) {
+	if err != nil {
+		log.Printf("Unable to read duration of %q: %v\n", result.Output, err)
+		return
+	}
+	result.ArchivedDuration = duration
+	if diff := duration - result.Duration; result.Duration > 0 && (diff > durationTolerance || diff < -durationTolerance) {
+		log.Printf("Duration of %q is %v, expected %v\n", result.Output, duration, result.Duration)
+	}
+}
+
+// copyUnchanged archives the recording as it is.
+func copyUnchanged(f *hdhomerun.Recording, destdir string) bool {
+	output := path.Join(destdir, archiveName(f, readVideoInfo(*f.LocalFilename))+".ts")
+
+	in, err := os.Open(*f.LocalFilename)
+	if err != nil {
+		log.Printf("Unable to open %q: %v\n", *f.LocalFilename, err)
+		return false
+	}
+	defer in.Close()
+
+	out, err := os.Create(output)
+	if err != nil {
+		log.Printf("Unable to create %q: %v\n", output, err)
+		return false
+	}
+
+	log.Printf("Copying %q to %q\n", *f.LocalFilename, output)
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("Unable to copy %q: %v\n", *f.LocalFilename, err)
+		os.Remove(output)
+		return false
+	}
+
+	result := analyzeSource(f, output)
+	var duration time.Duration
+	stats, err := mpegts.ScanFile(output)
+	if err == nil {
+		duration = stats.Duration
+	}
+	checkArchivedDuration(result, duration, err)
+	if err = writeArchiveResult(result); err != nil {
+		log.Printf("Unable to write archive result for %q: %v\n", output, err)
+	}
+
+	return true
+}
+
+// copyWithFFmpeg archives the recording to Matroska using ffmpeg instead of
+// mkvmerge.
+func copyWithFFmpeg(f *hdhomerun.Recording, destdir string) bool {
+	output := path.Join(destdir, archiveName(f, readVideoInfo(*f.LocalFilename))+".mkv")
+
+	ffcmd := ffmpeg.New()
+	ffcmd.SetInput(*f.LocalFilename)
+	ffcmd.SetOutput(output)
+	ffcmd.SetFormat("matroska")
+	// The Matroska muxer refuses data streams such as SCTE-35.
+	ffcmd.SetStreams("0:v", "0:a?", "0:s?")
+
+	ffcmd.SetMetadata("title", *f.Title)
+	if f.EpisodeTitle != nil && !f.IsMovie() {
+		ffcmd.SetMetadata("SUBTITLE", *f.EpisodeTitle)
+	}
+	if f.Synopsis != nil {
+		ffcmd.SetMetadata("SYNOPSIS", *f.Synopsis)
+	}
+
+	if err := ffcmd.Exec(); err != nil {
+		log.Printf("Failed to remux %q with ffmpeg: %v\n", *f.LocalFilename, err)
+		return false
+	}
+
+	result := analyzeSource(f, output)
+	duration, err := mkvmerge.Duration(output)
+	checkArchivedDuration(result, duration, err)
+	if err = writeArchiveResult(result); err != nil {
+		log.Printf("Unable to write archive result for %q: %v\n", output, err)
+	}
+
+	return true
+}
@@ -0,0 +1,65 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/viper"
+)
+
+func testStreams(types ...mpegts.StreamType) []*mpegts.Program {
+	p := &mpegts.Program{}
+	for i, t := range types {
+		p.Streams = append(p.Streams, &mpegts.Stream{Pid: int16(0x100 + i), Type: t, Codec: t.String()})
+	}
+	return []*mpegts.Program{p}
+}
+
+func TestProgramsPolicy(t *testing.T) {
+	ac4 := testStreams(mpegts.StreamHEVC, mpegts.StreamPrivate)
+	ac4[0].Streams[1].Codec = "AC-4"
+
+	tests := []struct {
+		name        string
+		programs    []*mpegts.Program
+		codec       map[string]string
+		unsupported string
+		policy      string
+		codecs      []string
+	}{
+		{"supported", testStreams(mpegts.StreamH264, mpegts.StreamAC3, mpegts.StreamSCTE35, mpegts.StreamATSCData), nil, "copy", "mkvmerge", nil},
+		{"AC-4 default", ac4, nil, "skip", "copy", []string{"AC-4 (PID 0x0101)"}},
+		{"AC-4 configured", ac4, map[string]string{"ac-4": "FFmpeg"}, "copy", "ffmpeg", []string{"AC-4 (PID 0x0101)"}},
+		{"MPEG-H", testStreams(mpegts.StreamHEVC, mpegts.StreamMPEGH), nil, "remux", "remux", []string{"MPEG-H 3D Audio (PID 0x0101)"}},
+		{"data streams", testStreams(mpegts.StreamH264, mpegts.StreamType(0x99), mpegts.StreamPrivate, mpegts.StreamSCTE35), nil, "copy", "mkvmerge", nil},
+		{"strictest wins", testStreams(mpegts.StreamMPEGH, mpegts.StreamType(0x99)), map[string]string{"0x99": "skip"}, "remux", "skip",
+			[]string{"MPEG-H 3D Audio (PID 0x0100)", "0x99 (PID 0x0101)"}},
+	}
+
+	defer viper.Reset()
+	for _, tt := range tests {
+		viper.Set("codec-policy", tt.codec)
+		viper.Set("unsupported-policy", tt.unsupported)
+		policy, codecs := programsPolicy(tt.programs)
+		if policy != tt.policy || !reflect.DeepEqual(codecs, tt.codecs) {
+			t.Errorf("%s: got %q %q, want %q %q", tt.name, policy, codecs, tt.policy, tt.codecs)
+		}
+	}
+}
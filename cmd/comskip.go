@@ -0,0 +1,79 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/comskip"
+	"github.com/saintdev/hdhrdvrutil/edl"
+
+	"github.com/spf13/viper"
+)
+
+var detectCommercials = false
+
+func init() {
+	archiveCmd.Flags().BoolVarP(&detectCommercials, "comskip", "", false, "Detect commercials with comskip")
+	archiveCmd.Flags().StringP("comskip-path", "", "comskip", "Path to a comskip compatible detector")
+	archiveCmd.Flags().StringP("comskip-ini", "", "", "Comskip ini file")
+
+	viper.BindPFlag("comskip-path", archiveCmd.Flags().Lookup("comskip-path"))
+	viper.BindPFlag("comskip-ini", archiveCmd.Flags().Lookup("comskip-ini"))
+}
+
+// comskipBreaks runs the commercial detector on the recording. Failures are
+// reported and no breaks are returned, so archiving can go on without them.
+func comskipBreaks(filename string, duration time.Duration) []edl.Entry {
+	dir, err := ioutil.TempDir("", "comskip")
+	if err != nil {
+		log.Printf("Unable to create comskip output directory: %v\n", err)
+		return nil
+	}
+	defer os.RemoveAll(dir)
+
+	c := comskip.New()
+	c.SetCommand(viper.GetString("comskip-path"))
+	c.SetIni(viper.GetString("comskip-ini"))
+	c.SetInput(filename)
+	c.SetOutputDir(dir)
+
+	if err = c.Exec(); err != nil {
+		log.Printf("Commercial detection failed for %q: %v\n", filename, err)
+		return nil
+	}
+
+	entries, err := c.Breaks()
+	if err != nil {
+		log.Printf("Unable to read comskip output for %q: %v\n", filename, err)
+		return nil
+	}
+
+	var breaks []edl.Entry
+	for _, e := range entries {
+		if duration > 0 && e.End > duration {
+			e.End = duration
+		}
+		if e.End > e.Start {
+			breaks = append(breaks, e)
+		}
+	}
+
+	return breaks
+}
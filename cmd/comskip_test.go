@@ -0,0 +1,75 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/edl"
+
+	"github.com/spf13/viper"
+)
+
+func TestComskipBreaks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "comskip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Breaks of 1-30s, 60-120s and 150-200s, the last one past the end of
+	// the recording.
+	script := `#!/bin/sh
+for a; do case "$a" in --output=*) out="${a#--output=}";; esac; done
+printf 'FILE PROCESSING COMPLETE  5000 FRAMES AT  2500\n-------------------\n25 750\n1500 3000\n3750 5000\n' > "$out/recording.txt"
+exit 1
+`
+	command := filepath.Join(dir, "comskip")
+	if err := ioutil.WriteFile(command, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer viper.Set("comskip-path", "comskip")
+
+	tests := []struct {
+		name     string
+		command  string
+		duration time.Duration
+		breaks   []edl.Entry
+	}{
+		{"unknown duration", command, 0, []edl.Entry{
+			{Start: time.Second, End: 30 * time.Second, Action: edl.Commercial},
+			{Start: time.Minute, End: 2 * time.Minute, Action: edl.Commercial},
+			{Start: 150 * time.Second, End: 200 * time.Second, Action: edl.Commercial},
+		}},
+		{"clamped to the recording", command, 100 * time.Second, []edl.Entry{
+			{Start: time.Second, End: 30 * time.Second, Action: edl.Commercial},
+			{Start: time.Minute, End: 100 * time.Second, Action: edl.Commercial},
+		}},
+		{"missing detector", filepath.Join(dir, "missing"), 0, nil},
+	}
+
+	for _, tt := range tests {
+		viper.Set("comskip-path", tt.command)
+		if breaks := comskipBreaks(filepath.Join(dir, "recording.ts"), tt.duration); !reflect.DeepEqual(breaks, tt.breaks) {
+			t.Errorf("%s: got %v, want %v", tt.name, breaks, tt.breaks)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/edl"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+)
+
+var (
+	cutCommercials = false
+	cutRanges      []string
+)
+
+func init() {
+	archiveCmd.Flags().BoolVarP(&cutCommercials, "cut-commercials", "", false, "Remove detected commercials and those in an EDL file next to the recording")
+	archiveCmd.Flags().StringSliceVarP(&cutRanges, "cut", "", nil, "EDL file or time range (start-end) to remove")
+}
+
+// cutList collects the ranges to remove from the recording.
+func cutList(filename string, breaks []edl.Entry) []edl.Entry {
+	var cuts []edl.Entry
+
+	if cutCommercials {
+		cuts = append(cuts, breaks...)
+
+		sidecar := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".edl"
+		if entries, err := edl.ReadFile(sidecar); err == nil {
+			cuts = append(cuts, entries...)
+		} else if !os.IsNotExist(err) {
+			log.Printf("Unable to read %q: %v\n", sidecar, err)
+		}
+	}
+
+	for _, c := range cutRanges {
+		if _, err := os.Stat(c); err == nil {
+			entries, err := edl.ReadFile(c)
+			if err != nil {
+				log.Printf("Unable to read %q: %v\n", c, err)
+				continue
+			}
+			cuts = append(cuts, entries...)
+		} else if e, err := edl.ParseRange(c); err == nil {
+			cuts = append(cuts, e)
+		} else {
+			log.Println(err)
+		}
+	}
+
+	var removed []edl.Entry
+	for _, c := range cuts {
+		// Mute and scene markers don't remove anything.
+		if c.Action == edl.Cut || c.Action == edl.Commercial {
+			removed = append(removed, c)
+		}
+	}
+
+	return mergeBreaks(removed)
+}
+
+// cutParts returns the parts of span left after removing the cuts. duration
+// is the length of the stream, or zero if unknown.
+func cutParts(span mkvmerge.Part, duration time.Duration, cuts []edl.Entry) []mkvmerge.Part {
+	var parts []mkvmerge.Part
+
+	end := span.End
+	if end == 0 {
+		end = duration
+	}
+	if end == 0 {
+		end = math.MaxInt64
+	}
+
+	pos := span.Start
+	for _, c := range cuts {
+		if c.End <= pos {
+			continue
+		}
+		if c.Start >= end {
+			break
+		}
+		if c.Start > pos {
+			parts = append(parts, mkvmerge.Part{Start: pos, End: c.Start})
+		}
+		pos = c.End
+	}
+	if pos < end {
+		parts = append(parts, mkvmerge.Part{Start: pos})
+		if end != duration && end != math.MaxInt64 {
+			parts[len(parts)-1].End = end
+		}
+	}
+
+	return parts
+}
+
+// partsDuration returns the length of the joined parts, or zero if unknown.
+func partsDuration(parts []mkvmerge.Part, duration time.Duration) time.Duration {
+	var total time.Duration
+	for _, p := range parts {
+		end := p.End
+		if end == 0 {
+			if duration == 0 {
+				return 0
+			}
+			end = duration
+		}
+		total += end - p.Start
+	}
+	return total
+}
+
+// shiftTime moves t onto the timeline of the joined parts.
+func shiftTime(t time.Duration, parts []mkvmerge.Part) (time.Duration, bool) {
+	var offset time.Duration
+	for _, p := range parts {
+		if t >= p.Start && (p.End == 0 || t < p.End) {
+			return t - p.Start + offset, true
+		}
+		offset += p.End - p.Start
+	}
+	return 0, false
+}
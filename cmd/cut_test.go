@@ -0,0 +1,106 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/edl"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+)
+
+func cut(start, end time.Duration) edl.Entry {
+	return edl.Entry{Start: start, End: end, Action: edl.Cut}
+}
+
+func part(start, end time.Duration) mkvmerge.Part {
+	return mkvmerge.Part{Start: start, End: end}
+}
+
+func TestCutParts(t *testing.T) {
+	const m = time.Minute
+
+	tests := []struct {
+		name     string
+		span     mkvmerge.Part
+		duration time.Duration
+		cuts     []edl.Entry
+		parts    []mkvmerge.Part
+	}{
+		{"no cuts", mkvmerge.Part{}, 60 * m, nil, []mkvmerge.Part{part(0, 0)}},
+		{"middle", mkvmerge.Part{}, 60 * m, []edl.Entry{cut(10*m, 15*m), cut(30*m, 33*m)},
+			[]mkvmerge.Part{part(0, 10*m), part(15*m, 30*m), part(33*m, 0)}},
+		{"start and end", mkvmerge.Part{}, 60 * m, []edl.Entry{cut(0, 2*m), cut(55*m, 60*m)},
+			[]mkvmerge.Part{part(2*m, 55*m)}},
+		{"past the end", mkvmerge.Part{}, 60 * m, []edl.Entry{cut(58*m, 70*m), cut(80*m, 90*m)},
+			[]mkvmerge.Part{part(0, 58*m)}},
+		{"unknown duration", mkvmerge.Part{}, 0, []edl.Entry{cut(10*m, 15*m)},
+			[]mkvmerge.Part{part(0, 10*m), part(15*m, 0)}},
+		{"trimmed span", mkvmerge.Part{Start: 2 * m, End: 32 * m}, 35 * m, []edl.Entry{cut(0, 3*m), cut(10*m, 12*m), cut(31*m, 40*m)},
+			[]mkvmerge.Part{part(3*m, 10*m), part(12*m, 31*m)}},
+		{"span to the end", mkvmerge.Part{Start: 2 * m, End: 35 * m}, 35 * m, []edl.Entry{cut(10*m, 12*m)},
+			[]mkvmerge.Part{part(2*m, 10*m), part(12*m, 0)}},
+		{"everything", mkvmerge.Part{}, 60 * m, []edl.Entry{cut(0, 60*m)}, nil},
+	}
+
+	for _, tt := range tests {
+		if parts := cutParts(tt.span, tt.duration, tt.cuts); !reflect.DeepEqual(parts, tt.parts) {
+			t.Errorf("%s: got %v, want %v", tt.name, parts, tt.parts)
+		}
+	}
+}
+
+func TestPartsDuration(t *testing.T) {
+	tests := []struct {
+		parts    []mkvmerge.Part
+		duration time.Duration
+		want     time.Duration
+	}{
+		{[]mkvmerge.Part{part(0, 10*time.Minute), part(15*time.Minute, 30*time.Minute)}, 0, 25 * time.Minute},
+		{[]mkvmerge.Part{part(0, 10*time.Minute), part(15*time.Minute, 0)}, time.Hour, 55 * time.Minute},
+		{[]mkvmerge.Part{part(0, 10*time.Minute), part(15*time.Minute, 0)}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := partsDuration(tt.parts, tt.duration); got != tt.want {
+			t.Errorf("partsDuration(%v, %v) = %v, want %v", tt.parts, tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestShiftTime(t *testing.T) {
+	parts := []mkvmerge.Part{part(time.Minute, 10*time.Minute), part(20*time.Minute, 0)}
+
+	tests := []struct {
+		t    time.Duration
+		want time.Duration
+		ok   bool
+	}{
+		{0, 0, false},
+		{time.Minute, 0, true},
+		{5 * time.Minute, 4 * time.Minute, true},
+		{15 * time.Minute, 0, false},
+		{25 * time.Minute, 14 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		if got, ok := shiftTime(tt.t, parts); got != tt.want || ok != tt.ok {
+			t.Errorf("shiftTime(%v) = %v %v, want %v %v", tt.t, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/cobra"
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe SRC [DEST]",
+	Short: "Remove duplicate recordings",
+	Long: `Find recordings of the same episode in srcdir, and in the archive in
+destdir if given, keep the best copy and delete or skip the rest.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run:  dedupeMain,
+}
+
+func init() {
+	rootCmd.AddCommand(dedupeCmd)
+
+	dedupeCmd.Flags().BoolVarP(&deleteRecordings, "delete", "", false, "Delete duplicate recordings from the DVR and the archive")
+}
+
+type rankedRecording struct {
+	*hdhomerun.Recording
+	stats *mpegts.Stats
+	video *mpegts.VideoInfo
+	// The archive result of a copy in the archive, empty for recordings on
+	// the DVR.
+	sidecar string
+}
+
+func (r *rankedRecording) completeness() float64 {
+	scheduled := r.ScheduledDuration()
+	if scheduled <= 0 {
+		return 1
+	}
+	if c := r.stats.Duration.Seconds() / scheduled.Seconds(); c < 1 {
+		return c
+	}
+	return 1
+}
+
+// pixels returns the pixel rates of a and b, or just their frame sizes if
+// either frame rate is unknown.
+func pixels(a, b *mpegts.VideoInfo) (float64, float64) {
+	if a.FrameRate > 0 && b.FrameRate > 0 {
+		return a.Pixels(), b.Pixels()
+	}
+	return float64(a.Width * a.Height), float64(b.Width * b.Height)
+}
+
+// better reports whether a is a better copy than b, going by resolution,
+// bitrate, duration and errors. Small differences in resolution, bitrate
+// and duration are ignored so the comparison falls through to the next
+// criteria.
+func better(a, b *rankedRecording) bool {
+	if a.video != nil && b.video != nil {
+		if ap, bp := pixels(a.video, b.video); ap > bp*1.1 || bp > ap*1.1 {
+			return ap > bp
+		}
+	}
+	if ab, bb := a.stats.Bitrate(), b.stats.Bitrate(); ab > bb*1.25 || bb > ab*1.25 {
+		return ab > bb
+	}
+	if ac, bc := a.completeness(), b.completeness(); ac-bc > 0.02 || bc-ac > 0.02 {
+		return ac > bc
+	}
+	return a.stats.Errors() < b.stats.Errors()
+}
+
+// bestCopy returns the best of ranked. better isn't transitive because of its
+// tolerances, so rather than sorting the copies are scanned once, archived
+// copies first and then in filename order, keeping the earlier file when
+// neither is better.
+func bestCopy(ranked []*rankedRecording) *rankedRecording {
+	sort.Slice(ranked, func(i, j int) bool {
+		if ai, aj := ranked[i].sidecar != "", ranked[j].sidecar != ""; ai != aj {
+			return ai
+		}
+		return *ranked[i].LocalFilename < *ranked[j].LocalFilename
+	})
+
+	best := ranked[0]
+	for _, r := range ranked[1:] {
+		if better(r, best) {
+			best = r
+		}
+	}
+
+	return best
+}
+
+// archivedCopies reads the archive results in dir, for the recordings
+// archived there.
+func archivedCopies(dir string) []*rankedRecording {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		log.Printf("Unable to list %q: %v\n", dir, err)
+		return nil
+	}
+
+	var archived []*rankedRecording
+	for _, name := range names {
+		jsonBuf, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Printf("Unable to read %q: %v\n", name, err)
+			continue
+		}
+		result := &archiveResult{}
+		if err = json.Unmarshal(jsonBuf, result); err != nil || result.Recording == nil {
+			// Older archive results don't say what was recorded.
+			continue
+		}
+
+		r := result.Recording
+		r.LocalFilename = &result.Output
+		if r.EpisodeString != nil {
+			if e, err := hdhomerun.ParseEpisodeNumber(*r.EpisodeString); err == nil {
+				r.Season, r.Episode, r.Episodes = e.Season, e.Episode(), e.Episodes
+			}
+		}
+
+		// The streams are those of the recording the archive was made from.
+		stats := &mpegts.Stats{Duration: result.Duration}
+		for _, p := range result.Streams {
+			stats.Packets += p.Packets
+			stats.TransportErrors += p.TransportErrors
+			stats.ContinuityErrors += p.ContinuityErrors
+		}
+
+		archived = append(archived, &rankedRecording{r, stats, result.Video, name})
+	}
+
+	return archived
+}
+
+// removeArchived deletes an archived copy along with its archive result and
+// EDL.
+func removeArchived(r *rankedRecording) {
+	for _, name := range []string{*r.LocalFilename, r.sidecar, strings.TrimSuffix(*r.LocalFilename, filepath.Ext(*r.LocalFilename)) + ".edl"} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove %q: %v\n", name, err)
+		}
+	}
+}
+
+// findDuplicates returns the duplicate copies that should be discarded in
+// favour of a better copy, among the recordings and the archived copies.
+func findDuplicates(recordings []*hdhomerun.Recording, archived []*rankedRecording) []*rankedRecording {
+	var local []*hdhomerun.Recording
+	ranks := map[*hdhomerun.Recording]*rankedRecording{}
+	for _, r := range recordings {
+		if r.LocalFilename != nil {
+			local = append(local, r)
+		}
+	}
+	for _, r := range archived {
+		local = append(local, r.Recording)
+		ranks[r.Recording] = r
+	}
+
+	var discard []*rankedRecording
+	for _, group := range hdhomerun.FindDuplicates(local) {
+		var ranked []*rankedRecording
+		for _, r := range group {
+			if c, ok := ranks[r]; ok {
+				ranked = append(ranked, c)
+				continue
+			}
+			stats, err := mpegts.ScanFile(*r.LocalFilename)
+			if err != nil {
+				log.Printf("Unable to scan %q: %v\n", *r.LocalFilename, err)
+				continue
+			}
+			ranked = append(ranked, &rankedRecording{r, stats, readVideoInfo(*r.LocalFilename), ""})
+		}
+		if len(ranked) < 2 {
+			continue
+		}
+
+		best := bestCopy(ranked)
+		log.Printf("Keeping %q\n", *best.LocalFilename)
+		for _, r := range ranked {
+			if r != best {
+				discard = append(discard, r)
+			}
+		}
+	}
+
+	return discard
+}
+
+func dedupeMain(cmd *cobra.Command, args []string) {
+	srcDir = absDir(args[0])
+
+	var archived []*rankedRecording
+	if len(args) > 1 {
+		destDir = absDir(args[1])
+		archived = archivedCopies(destDir)
+	}
+
+	dvrClient := hdhomerun.NewClient(nil)
+	recordings := fetchRecordings(dvrClient)
+
+	scanRecordings(dvrClient, srcDir, recordings)
+
+	for _, r := range findDuplicates(recordings, archived) {
+		fmt.Printf("Duplicate: %s\n", *r.LocalFilename)
+
+		if !deleteRecordings {
+			continue
+		}
+		if r.sidecar != "" {
+			removeArchived(r)
+		} else if err := dvrClient.Recordings.Delete(r.Recording, false); err != nil {
+			log.Printf("Failed to delete recording %q: %v\n", *r.LocalFilename, err)
+		}
+	}
+}
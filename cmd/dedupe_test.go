@@ -0,0 +1,151 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+)
+
+func testCopy(name string, packets, errors int64, video *mpegts.VideoInfo) *rankedRecording {
+	return &rankedRecording{
+		Recording: &hdhomerun.Recording{LocalFilename: &name},
+		stats:     &mpegts.Stats{Packets: packets, Duration: time.Second, ContinuityErrors: errors},
+		video:     video,
+	}
+}
+
+func TestBestCopy(t *testing.T) {
+	hd := &mpegts.VideoInfo{Width: 1920, Height: 1080, FrameRate: 29.97}
+	sd := &mpegts.VideoInfo{Width: 720, Height: 480, FrameRate: 29.97}
+
+	tests := []struct {
+		name   string
+		copies []*rankedRecording
+		want   string
+	}{
+		{
+			// better isn't transitive here: b beats a and c, c beats a.
+			"tolerances",
+			[]*rankedRecording{
+				testCopy("a", 1000, 5, nil),
+				testCopy("b", 1200, 0, nil),
+				testCopy("c", 1400, 10, nil),
+			},
+			"b",
+		},
+		{
+			"resolution",
+			[]*rankedRecording{
+				testCopy("a", 3000, 0, sd),
+				testCopy("b", 1000, 20, hd),
+			},
+			"b",
+		},
+		{
+			// Without a frame rate only the frame sizes are compared.
+			"unknown frame rate",
+			[]*rankedRecording{
+				testCopy("a", 1000, 0, &mpegts.VideoInfo{Width: 1920, Height: 1080}),
+				testCopy("b", 1000, 0, &mpegts.VideoInfo{Width: 720, Height: 480, FrameRate: 59.94}),
+			},
+			"a",
+		},
+		{
+			"archived copy on a tie",
+			[]*rankedRecording{
+				testCopy("a", 1000, 0, hd),
+				{testCopy("b", 1000, 0, hd).Recording, &mpegts.Stats{Packets: 1000, Duration: time.Second}, hd, "b.json"},
+			},
+			"b",
+		},
+		{
+			"tie",
+			[]*rankedRecording{
+				testCopy("b", 1000, 0, hd),
+				testCopy("a", 1000, 0, hd),
+			},
+			"a",
+		},
+	}
+
+	for _, tt := range tests {
+		// The result mustn't depend on the order of the copies.
+		for i := range tt.copies {
+			copies := append(append([]*rankedRecording{}, tt.copies[i:]...), tt.copies[:i]...)
+			if got := *bestCopy(copies).LocalFilename; got != tt.want {
+				t.Errorf("%s: bestCopy from %q = %q, want %q", tt.name, *tt.copies[i].LocalFilename, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestArchivedDuplicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedupe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	programID, title := "EP012345670012", "The Show"
+	archive := func(name string, video *mpegts.VideoInfo, recording *hdhomerun.Recording) {
+		output := filepath.Join(dir, name+".mkv")
+		if err := ioutil.WriteFile(output, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		result := &archiveResult{
+			Output:    output,
+			ProgramID: &programID,
+			Duration:  time.Hour,
+			Streams:   []*mpegts.PidStats{{Pid: 0x31, Packets: 1000000}},
+			Recording: recording,
+			Video:     video,
+		}
+		if err := writeArchiveResult(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive("hd", &mpegts.VideoInfo{Width: 1920, Height: 1080, FrameRate: 29.97}, &hdhomerun.Recording{ProgramID: &programID, Title: &title})
+	archive("sd", &mpegts.VideoInfo{Width: 720, Height: 480, FrameRate: 29.97}, &hdhomerun.Recording{ProgramID: &programID, Title: &title})
+	// Older archive results can't be matched.
+	archive("old", nil, nil)
+
+	archived := archivedCopies(dir)
+	if len(archived) != 2 {
+		t.Fatalf("archivedCopies found %d copies, want 2", len(archived))
+	}
+
+	discard := findDuplicates(nil, archived)
+	if len(discard) != 1 || *discard[0].LocalFilename != filepath.Join(dir, "sd.mkv") {
+		t.Fatalf("findDuplicates discards %v, want sd.mkv", discard)
+	}
+
+	removeArchived(discard[0])
+	for _, name := range []string{"sd.mkv", "sd.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("removeArchived left %s behind", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hd.mkv")); err != nil {
+		t.Errorf("removeArchived removed the kept copy: %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import FILE... DEST",
+	Short: "Import recordings from other DVRs",
+	Long: `Add HDHomeRun metadata to MPEG-TS files and place them in the record
+engine storage directory destdir.`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  importMain,
+}
+
+var (
+	importJSON    = ""
+	importPattern = `^(?P<title>.+?)[ ._-]+(?P<episode>(?i:S\d+E\d+(?:-?E\d+)*))(?:[ ._-]+(?P<episode_title>.+))?$`
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVarP(&importJSON, "json", "", "", "Read metadata from a JSON file")
+	importCmd.Flags().StringVarP(&importPattern, "pattern", "", importPattern, "Regular expression extracting title, episode and episode_title from the file name, names that don't match are used as the title")
+	importCmd.Flags().StringVarP(&metaTitle, "title", "", "", "Program title")
+	importCmd.Flags().StringVarP(&metaEpisodeTitle, "episode-title", "", "", "Episode title")
+	importCmd.Flags().StringVarP(&metaEpisode, "episode", "", "", "Episode number, e.g. S02E05")
+	importCmd.Flags().StringVarP(&metaSynopsis, "synopsis", "", "", "Synopsis")
+}
+
+func importMetadata(cmd *cobra.Command, filename string, pattern *regexp.Regexp) (map[string]interface{}, error) {
+	// The file name is only a guess, the JSON and flags override it.
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	fields := fileNameFields(pattern, base)
+
+	if importJSON != "" {
+		jsonBuf, err := ioutil.ReadFile(importJSON)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(jsonBuf, &fields); err != nil {
+			return nil, err
+		}
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("title") {
+		fields["Title"] = metaTitle
+	}
+	if flags.Changed("episode-title") {
+		fields["EpisodeTitle"] = metaEpisodeTitle
+	}
+	if flags.Changed("episode") {
+		fields["EpisodeNumber"] = metaEpisode
+	}
+	if flags.Changed("synopsis") {
+		fields["Synopsis"] = metaSynopsis
+	}
+
+	if _, ok := fields["Title"].(string); !ok {
+		return nil, fmt.Errorf("No title for %q", filename)
+	}
+
+	if s, ok := fields["EpisodeNumber"].(string); ok {
+		e, err := hdhomerun.ParseEpisodeNumber(s)
+		if err != nil {
+			return nil, err
+		}
+		fields["EpisodeNumber"] = e.String()
+	}
+	if _, ok := fields["Category"]; !ok {
+		if _, ok := fields["EpisodeNumber"]; ok {
+			fields["Category"] = "series"
+		} else {
+			fields["Category"] = "movie"
+		}
+	}
+
+	return fields, nil
+}
+
+// fileNameFields extracts metadata from the file name base using pattern. Names
+// that don't match are taken as the title.
+func fileNameFields(pattern *regexp.Regexp, base string) map[string]interface{} {
+	fields := map[string]interface{}{}
+
+	// Names without spaces use dots or underscores instead.
+	clean := strings.TrimSpace
+	if !strings.Contains(base, " ") {
+		clean = func(s string) string {
+			return strings.TrimSpace(strings.NewReplacer(".", " ", "_", " ").Replace(s))
+		}
+	}
+
+	m := pattern.FindStringSubmatch(base)
+	if m == nil {
+		fields["Title"] = clean(base)
+		return fields
+	}
+
+	keys := map[string]string{
+		"title":         "Title",
+		"episode":       "EpisodeNumber",
+		"episode_title": "EpisodeTitle",
+		"synopsis":      "Synopsis",
+	}
+	for i, name := range pattern.SubexpNames() {
+		if key, ok := keys[name]; ok && m[i] != "" {
+			fields[key] = clean(m[i])
+		}
+	}
+
+	return fields
+}
+
+// setRecordTimes fills in the record times from the end time of the
+// recording and the duration of the stream, unless they are already known.
+func setRecordTimes(fields map[string]interface{}, filename string, end time.Time) (time.Time, error) {
+	if t, ok := fields["RecordStartTime"].(float64); ok {
+		return time.Unix(int64(t), 0), nil
+	}
+
+	stats, err := mpegts.ScanFile(filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	start := end.Add(-stats.Duration)
+
+	fields["RecordStartTime"] = start.Unix()
+	fields["RecordEndTime"] = end.Unix()
+	if _, ok := fields["StartTime"]; !ok {
+		fields["StartTime"] = start.Unix()
+		fields["EndTime"] = end.Unix()
+	}
+	fields["RecordSuccess"] = 1
+
+	return start, nil
+}
+
+// recordingPath returns the path of a recording following the record engine
+// layout: "Title/Title S01E02 20180102 [20180102-2000].mpg".
+func recordingPath(dest string, fields map[string]interface{}, start time.Time) string {
+	title := fields["Title"].(string)
+	folder := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, title)
+
+	name := folder
+	if e, ok := fields["EpisodeNumber"].(string); ok {
+		name += " " + e
+	}
+	name += fmt.Sprintf(" %s [%s].mpg", start.Format("20060102"), start.Format("20060102-1504"))
+
+	return filepath.Join(dest, folder, name)
+}
+
+// writeRecording stores the MPEG-TS file input with the metadata in fields
+// in the record engine layout below dest.
+func writeRecording(input, dest string, fields map[string]interface{}, end time.Time) (string, error) {
+	start, err := setRecordTimes(fields, input, end)
+	if err != nil {
+		return "", err
+	}
+
+	jsonBuf, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	output := recordingPath(dest, fields, start)
+	if err = os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return "", err
+	}
+	if _, err = os.Stat(output); err == nil {
+		return "", fmt.Errorf("%q already exists", output)
+	}
+
+	in, err := os.Open(input)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.Create(output)
+	if err != nil {
+		return "", err
+	}
+
+	err = mpegts.CopyWithMetadata(out, in, jsonBuf)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(output)
+		return "", err
+	}
+
+	return output, nil
+}
+
+func importFile(cmd *cobra.Command, filename, dest string, pattern *regexp.Regexp) error {
+	fields, err := importMetadata(cmd, filename, pattern)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	output, err := writeRecording(filename, dest, fields, info.ModTime())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Imported %q as %q\n", filename, output)
+
+	return nil
+}
+
+func importMain(cmd *cobra.Command, args []string) {
+	dest := absDir(args[len(args)-1])
+
+	pattern, err := regexp.Compile(importPattern)
+	if err != nil {
+		log.Fatalf("Invalid pattern %q: %v\n", importPattern, err)
+	}
+
+	failed := false
+	for _, filename := range args[:len(args)-1] {
+		if err := importFile(cmd, filename, dest, pattern); err != nil {
+			log.Printf("Failed to import %q: %v\n", filename, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
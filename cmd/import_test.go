@@ -0,0 +1,110 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestFileNameFields(t *testing.T) {
+	pattern := regexp.MustCompile(importPattern)
+
+	tests := []struct {
+		base string
+		want map[string]interface{}
+	}{
+		{"The Office S02E05 Halloween", map[string]interface{}{
+			"Title": "The Office", "EpisodeNumber": "S02E05", "EpisodeTitle": "Halloween",
+		}},
+		{"The Office - S02E05 - Halloween", map[string]interface{}{
+			"Title": "The Office", "EpisodeNumber": "S02E05", "EpisodeTitle": "Halloween",
+		}},
+		{"The.Office.S02E05.Halloween", map[string]interface{}{
+			"Title": "The Office", "EpisodeNumber": "S02E05", "EpisodeTitle": "Halloween",
+		}},
+		{"doctor_who_s10e01_the_pilot", map[string]interface{}{
+			"Title": "doctor who", "EpisodeNumber": "s10e01", "EpisodeTitle": "the pilot",
+		}},
+		{"Star Trek S01E01", map[string]interface{}{
+			"Title": "Star Trek", "EpisodeNumber": "S01E01",
+		}},
+		{"Lost S01E01-E02 Pilot", map[string]interface{}{
+			"Title": "Lost", "EpisodeNumber": "S01E01-E02", "EpisodeTitle": "Pilot",
+		}},
+		{"Mr. Robot S01E01 eps1.0_hellofriend.mov", map[string]interface{}{
+			"Title": "Mr. Robot", "EpisodeNumber": "S01E01", "EpisodeTitle": "eps1.0_hellofriend.mov",
+		}},
+		{"The Big Lebowski (1998)", map[string]interface{}{
+			"Title": "The Big Lebowski (1998)",
+		}},
+		{"Casablanca", map[string]interface{}{
+			"Title": "Casablanca",
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := fileNameFields(pattern, tt.base); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fileNameFields(%q) = %v, want %v", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestImportMetadata(t *testing.T) {
+	pattern := regexp.MustCompile(importPattern)
+
+	file, err := ioutil.TempFile("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{"Title": "The Office (US)", "Synopsis": "Michael has to fire someone."}`)
+	file.Close()
+
+	tests := []struct {
+		name     string
+		json     string
+		filename string
+		want     map[string]interface{}
+	}{
+		{"file name only", "", "/in/The Office S02E05 Halloween.ts", map[string]interface{}{
+			"Title": "The Office", "EpisodeNumber": "S02E05", "EpisodeTitle": "Halloween", "Category": "series",
+		}},
+		{"JSON over a matching name", file.Name(), "/in/The Office S02E05 Halloween.ts", map[string]interface{}{
+			"Title": "The Office (US)", "EpisodeNumber": "S02E05", "EpisodeTitle": "Halloween",
+			"Synopsis": "Michael has to fire someone.", "Category": "series",
+		}},
+		{"JSON over an unmatched name", file.Name(), "/in/office-halloween.ts", map[string]interface{}{
+			"Title": "The Office (US)", "Synopsis": "Michael has to fire someone.", "Category": "movie",
+		}},
+	}
+
+	defer func() { importJSON = "" }()
+	for _, tt := range tests {
+		importJSON = tt.json
+		fields, err := importMetadata(importCmd, tt.filename, pattern)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(fields, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, fields, tt.want)
+		}
+	}
+}
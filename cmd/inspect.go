@@ -0,0 +1,180 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect FILE...",
+	Short: "Show the metadata and streams of recordings",
+	Long:  `Print the HDHomeRun metadata and elementary streams of each recording as JSON or a table.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run:   inspectMain,
+}
+
+var inspectFormat = "json"
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+
+	inspectCmd.Flags().StringVarP(&inspectFormat, "format", "f", inspectFormat, "Output format (json, table)")
+}
+
+type inspectResult struct {
+	Filename string
+	Metadata json.RawMessage `json:",omitempty"`
+	Programs []*mpegts.Program
+	Duration time.Duration
+	Pids     []*mpegts.PidStats
+}
+
+func inspectFile(filename string) (*inspectResult, error) {
+	result := &inspectResult{Filename: filename}
+
+	jsonBuf, err := mpegts.ReadMetadataFile(filename)
+	if err != nil && err != mpegts.ErrNoMetadata {
+		return nil, err
+	}
+	result.Metadata = jsonBuf
+
+	if result.Programs, err = mpegts.ReadProgramsFile(filename); err != nil {
+		return nil, err
+	}
+
+	report, err := mpegts.AnalyzeFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	result.Duration = report.Duration
+	result.Pids = report.SortedPids()
+
+	return result, nil
+}
+
+func printMetadataTable(jsonBuf []byte) error {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(jsonBuf, &fields); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%v\n", k, fields[k])
+	}
+
+	return w.Flush()
+}
+
+func printStreamTable(result *inspectResult) error {
+	pids := map[int16]*mpegts.PidStats{}
+	for _, p := range result.Pids {
+		pids[p.Pid] = p
+	}
+
+	fmt.Printf("Duration: %v\n", result.Duration)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Program\tPID\tType\tLanguage\tFirst PTS\tLast PTS\tAvg kbit/s\tPeak kbit/s\tCaptions")
+	for _, p := range result.Programs {
+		for _, s := range p.Streams {
+			var captions []string
+			for _, c := range s.Captions {
+				kind := "CC"
+				if c.Digital {
+					kind = "Service "
+				}
+				captions = append(captions, fmt.Sprintf("%s%d (%s)", kind, c.Service, c.Language))
+			}
+
+			stats, ok := pids[s.Pid]
+			if !ok {
+				stats = &mpegts.PidStats{}
+			}
+
+			fmt.Fprintf(w, "%d\t0x%04X\t%s\t%s\t%d\t%d\t%.0f\t%.0f\t%s\n", p.Number, s.Pid, s.Codec, s.Language,
+				stats.FirstPTS, stats.LastPTS, stats.AvgBitrate/1000, stats.PeakBitrate/1000, strings.Join(captions, ", "))
+		}
+	}
+
+	return w.Flush()
+}
+
+func inspectMain(cmd *cobra.Command, args []string) {
+	if inspectFormat != "json" && inspectFormat != "table" {
+		log.Fatalf("Unknown format %q", inspectFormat)
+	}
+
+	var results []*inspectResult
+
+	failed := false
+	for _, filename := range args {
+		result, err := inspectFile(filename)
+		if err != nil {
+			log.Printf("Unable to inspect %q: %v\n", filename, err)
+			failed = true
+			continue
+		}
+
+		switch inspectFormat {
+		case "table":
+			fmt.Printf("%v:\n", filename)
+			if result.Metadata != nil {
+				err = printMetadataTable(result.Metadata)
+				fmt.Println("")
+			}
+			if err == nil {
+				err = printStreamTable(result)
+			}
+		case "json":
+			results = append(results, result)
+		}
+		if err != nil {
+			log.Printf("Unable to print %q: %v\n", filename, err)
+			failed = true
+		}
+	}
+
+	if inspectFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
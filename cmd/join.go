@@ -0,0 +1,149 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+)
+
+// recordsAiring reports whether r was recording during its scheduled airing.
+func recordsAiring(r *hdhomerun.Recording) bool {
+	if r.StartTime == nil || r.EndTime == nil || r.RecordStartTime == nil || r.RecordEndTime == nil {
+		return false
+	}
+	return *r.RecordStartTime < *r.EndTime && *r.RecordEndTime > *r.StartTime
+}
+
+// Largest distance between the end of one part of a split recording and the
+// start of the next, either way. Parts overlapping by more were recorded at
+// the same time, e.g. an HD and SD simulcast.
+const maxSplitDistance = 5 * time.Minute
+
+// splitGroups finds airings that were recorded into several files, the
+// record engine starts a new file when it restarts or the tuner drops. Each
+// group has the parts of one airing on one channel in time order, each part
+// starting near the end of the one before it.
+func splitGroups(recordings []*hdhomerun.Recording) [][]*hdhomerun.Recording {
+	type airing struct {
+		programID string
+		channel   string
+		start     int64
+	}
+
+	airings := map[airing][]*hdhomerun.Recording{}
+	var order []airing
+	for _, r := range recordings {
+		if r.LocalFilename == nil || r.ProgramID == nil || r.ChannelNumber == nil || !recordsAiring(r) {
+			continue
+		}
+		key := airing{*r.ProgramID, *r.ChannelNumber, *r.StartTime}
+		if _, ok := airings[key]; !ok {
+			order = append(order, key)
+		}
+		airings[key] = append(airings[key], r)
+	}
+
+	distance := int64(maxSplitDistance / time.Second)
+
+	var groups [][]*hdhomerun.Recording
+	for _, key := range order {
+		parts := airings[key]
+		sort.SliceStable(parts, func(i, j int) bool {
+			return *parts[i].RecordStartTime < *parts[j].RecordStartTime
+		})
+
+		// Parts left over when a chain breaks start chains of their own.
+		for len(parts) > 1 {
+			group := []*hdhomerun.Recording{parts[0]}
+			var rest []*hdhomerun.Recording
+			for _, r := range parts[1:] {
+				prev := group[len(group)-1]
+				start, end := *r.RecordStartTime, *prev.RecordEndTime
+				if start > *prev.RecordStartTime && start >= end-distance && start <= end+distance {
+					group = append(group, r)
+				} else {
+					rest = append(rest, r)
+				}
+			}
+			if len(group) > 1 {
+				groups = append(groups, group)
+			}
+			parts = rest
+		}
+	}
+
+	return groups
+}
+
+// gapChapters marks the gaps between joined parts, moved onto the timeline
+// of the parts kept in the archive.
+func gapChapters(gaps []mpegts.Gap, parts []mkvmerge.Part) []mkvmerge.ChapterMark {
+	var chapters []mkvmerge.ChapterMark
+
+	for i, g := range gaps {
+		if t, ok := shiftTime(g.Start, parts); ok {
+			name := fmt.Sprintf("Part %d (%v missing)", i+2, g.Duration.Round(time.Second))
+			chapters = append(chapters, mkvmerge.ChapterMark{Start: t, Name: name})
+		}
+	}
+	return chapters
+}
+
+// archiveJoined joins the parts of a split recording and archives them as
+// one. It returns false if the recording was not archived.
+func archiveJoined(group []*hdhomerun.Recording, destdir string) bool {
+	var files []string
+	for _, r := range group {
+		files = append(files, *r.LocalFilename)
+	}
+
+	tmp, err := ioutil.TempFile(destdir, ".join-*.ts")
+	if err != nil {
+		log.Printf("Unable to create temporary file: %v\n", err)
+		return false
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	log.Printf("Joining split recording %q\n", files)
+	result, err := mpegts.JoinFiles(tmp.Name(), files)
+	if err != nil {
+		log.Printf("Unable to join %q: %v\n", files, err)
+		return false
+	}
+	for _, g := range result.Gaps {
+		log.Printf("Gap of %v at %v\n", g.Duration.Round(time.Second), g.Start.Round(time.Second))
+	}
+	if result.Unaligned > 0 {
+		log.Printf("%d parts of %q don't share timestamps, gaps between them are unknown\n", result.Unaligned, files)
+	}
+
+	joined := *group[0]
+	name := tmp.Name()
+	joined.LocalFilename = &name
+	joined.RecordEndTime = group[len(group)-1].RecordEndTime
+
+	return archiveRecording(&joined, destdir, result.Gaps)
+}
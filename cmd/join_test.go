@@ -0,0 +1,114 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+)
+
+func testRecording(name, channel string, start, end int64) *hdhomerun.Recording {
+	programID, airStart, airEnd := "EP012345670012", int64(1000), int64(4600)
+	return &hdhomerun.Recording{
+		LocalFilename:   &name,
+		ProgramID:       &programID,
+		ChannelNumber:   &channel,
+		StartTime:       &airStart,
+		EndTime:         &airEnd,
+		RecordStartTime: &start,
+		RecordEndTime:   &end,
+	}
+}
+
+func TestSplitGroups(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordings []*hdhomerun.Recording
+		want       [][]string
+	}{
+		{
+			"single recording",
+			[]*hdhomerun.Recording{testRecording("a", "5.1", 970, 4630)},
+			nil,
+		},
+		{
+			"restarted recording",
+			[]*hdhomerun.Recording{
+				testRecording("b", "5.1", 2000, 4630),
+				testRecording("a", "5.1", 970, 1900),
+			},
+			[][]string{{"a", "b"}},
+		},
+		{
+			"three parts",
+			[]*hdhomerun.Recording{
+				testRecording("a", "5.1", 970, 1900),
+				testRecording("b", "5.1", 1900, 3000),
+				testRecording("c", "5.1", 3010, 4630),
+			},
+			[][]string{{"a", "b", "c"}},
+		},
+		{
+			"simulcast",
+			[]*hdhomerun.Recording{
+				testRecording("hd", "5.1", 970, 4630),
+				testRecording("sd", "5.2", 970, 4630),
+			},
+			nil,
+		},
+		{
+			"simultaneous recordings",
+			[]*hdhomerun.Recording{
+				testRecording("a", "5.1", 970, 4630),
+				testRecording("b", "5.1", 1000, 4630),
+			},
+			nil,
+		},
+		{
+			"split and duplicate",
+			[]*hdhomerun.Recording{
+				testRecording("a", "5.1", 970, 1900),
+				testRecording("full", "5.1", 980, 4630),
+				testRecording("b", "5.1", 1950, 4630),
+			},
+			[][]string{{"a", "b"}},
+		},
+		{
+			"parts too far apart",
+			[]*hdhomerun.Recording{
+				testRecording("a", "5.1", 970, 1900),
+				testRecording("b", "5.1", 3000, 4630),
+			},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		var got [][]string
+		for _, g := range splitGroups(tt.recordings) {
+			var names []string
+			for _, r := range g {
+				names = append(names, *r.LocalFilename)
+			}
+			got = append(got, names)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitGroups = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
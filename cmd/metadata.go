@@ -0,0 +1,120 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/cobra"
+)
+
+var metadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "Manage the metadata embedded in recordings",
+}
+
+var metadataSetCmd = &cobra.Command{
+	Use:   "set FILE",
+	Short: "Change the metadata embedded in a recording",
+	Long: `Rewrite the HDHomeRun metadata embedded in a recording. Only the fields
+given on the command line are changed.`,
+	Args: cobra.ExactArgs(1),
+	Run:  metadataSetMain,
+}
+
+var (
+	metaTitle        = ""
+	metaEpisodeTitle = ""
+	metaEpisode      = ""
+	metaSynopsis     = ""
+	metaMovie        = false
+	metaProgramID    = ""
+)
+
+func init() {
+	rootCmd.AddCommand(metadataCmd)
+	metadataCmd.AddCommand(metadataSetCmd)
+
+	metadataSetCmd.Flags().StringVarP(&metaTitle, "title", "", "", "Program title")
+	metadataSetCmd.Flags().StringVarP(&metaEpisodeTitle, "episode-title", "", "", "Episode title")
+	metadataSetCmd.Flags().StringVarP(&metaEpisode, "episode", "", "", "Episode number, e.g. S02E05")
+	metadataSetCmd.Flags().StringVarP(&metaSynopsis, "synopsis", "", "", "Synopsis")
+	metadataSetCmd.Flags().BoolVarP(&metaMovie, "movie", "", false, "Mark the recording as a movie, removing any episode ProgramID")
+	metadataSetCmd.Flags().StringVarP(&metaProgramID, "program-id", "", "", "Gracenote ProgramID, e.g. MV001234560000")
+}
+
+func metadataSetMain(cmd *cobra.Command, args []string) {
+	filename := args[0]
+
+	jsonBuf, err := mpegts.ReadMetadataFile(filename)
+	if err != nil {
+		log.Fatalf("Unable to read metadata from %q: %v\n", filename, err)
+	}
+
+	// Decode into a map so fields we don't know about are preserved.
+	var fields map[string]interface{}
+	if err = json.Unmarshal(jsonBuf, &fields); err != nil {
+		log.Fatalf("Unable to parse metadata from %q: %v\n", filename, err)
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("title") {
+		fields["Title"] = metaTitle
+	}
+	if flags.Changed("episode-title") {
+		fields["EpisodeTitle"] = metaEpisodeTitle
+	}
+	if flags.Changed("synopsis") {
+		fields["Synopsis"] = metaSynopsis
+	}
+	if flags.Changed("episode") {
+		e, err := hdhomerun.ParseEpisodeNumber(metaEpisode)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fields["EpisodeNumber"] = e.String()
+	}
+	if metaMovie {
+		fields["Category"] = "movie"
+		delete(fields, "EpisodeNumber")
+		delete(fields, "EpisodeTitle")
+
+		// The movie's own ProgramID can't be derived from an episode's.
+		if id, ok := fields["ProgramID"].(string); ok {
+			if p, err := hdhomerun.ParseProgramID(id); err != nil || p.Type != hdhomerun.MovieProgram {
+				delete(fields, "ProgramID")
+			}
+		}
+	}
+	if flags.Changed("program-id") {
+		if _, err := hdhomerun.ParseProgramID(metaProgramID); err != nil {
+			log.Fatalln(err)
+		}
+		fields["ProgramID"] = metaProgramID
+	}
+
+	if jsonBuf, err = json.Marshal(fields); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err = mpegts.WriteMetadataFile(filename, jsonBuf); err != nil {
+		log.Fatalf("Unable to write metadata to %q: %v\n", filename, err)
+	}
+}
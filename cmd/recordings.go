@@ -0,0 +1,84 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"log"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+
+	"github.com/spf13/cobra"
+)
+
+var recordingsCmd = &cobra.Command{
+	Use:   "recordings",
+	Short: "Manage recordings",
+	Long:  `Inspect and manage the recordings stored by the HDHomeRun DVR service.`,
+}
+
+func init() {
+	rootCmd.AddCommand(recordingsCmd)
+}
+
+func fetchRecordings(dvrClient *hdhomerun.Client) []*hdhomerun.Recording {
+	var recordings []*hdhomerun.Recording
+
+	devices, err := dvrClient.Devices.Discover()
+	if err != nil {
+		log.Fatalf("Unable to discover devices: %v\n", err)
+	}
+
+	for _, device := range devices {
+		if !device.IsRecordEngine() {
+			continue
+		}
+
+		r, err := dvrClient.Devices.RecordedFiles(device)
+		if err != nil {
+			log.Printf("Failed to parse `recorded_files.json` for device at %q: %v\n", *device.BaseURL, err)
+			continue
+		}
+		recordings = append(recordings, r...)
+	}
+
+	if len(recordings) == 0 {
+		log.Fatalln("No recordings found!")
+	}
+
+	return recordings
+}
+
+// scanRecordings finds the files of recordings in dir. It returns recordings
+// for the files the DVR doesn't list that have metadata.
+func scanRecordings(dvrClient *hdhomerun.Client, dir string, recordings []*hdhomerun.Recording) []*hdhomerun.Recording {
+	result, err := dvrClient.Recordings.ScanRecordingsDir(dir, recordings)
+	if err != nil {
+		log.Fatalf("Error scanning recordings in %q: %v\n", dir, err)
+	}
+
+	for _, r := range result.UnmatchedRecordings {
+		name := "<unknown>"
+		if r.Filename != nil {
+			name = *r.Filename
+		}
+		log.Printf("No file found for recording %q\n", name)
+	}
+	for _, f := range result.UnmatchedFiles {
+		log.Printf("No recording found for file %q\n", f)
+	}
+
+	return result.Unlisted
+}
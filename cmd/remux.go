@@ -0,0 +1,91 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"log"
+	"path"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/viper"
+)
+
+// tsStreamFilter keeps video and the audio in the preferred languages. All
+// audio is kept if none of it is in a preferred language, data streams are
+// always dropped.
+func tsStreamFilter(programs []*mpegts.Program) mpegts.StreamFilter {
+	preferred := viper.GetStringSlice("audio-languages")
+
+	matched := false
+	for _, p := range programs {
+		for _, s := range p.Streams {
+			if s.IsAudio() && audioRank(s, preferred) < 2*len(preferred) {
+				matched = true
+			}
+		}
+	}
+
+	return func(p *mpegts.Program, s *mpegts.Stream) bool {
+		switch {
+		case s.IsVideo():
+			return true
+		case s.IsAudio():
+			return !matched || audioRank(s, preferred) < 2*len(preferred)
+		}
+		return false
+	}
+}
+
+// copyToTS archives f as a cleaned up transport stream, without mkvmerge. It
+// returns false if the archive couldn't be written.
+func copyToTS(f *hdhomerun.Recording, destdir string) bool {
+	output := path.Join(destdir, archiveName(f, readVideoInfo(*f.LocalFilename))+".ts")
+
+	programs, err := mpegts.ReadProgramsFile(*f.LocalFilename)
+	if err != nil {
+		log.Printf("Unable to read PMT from %q: %v\n", *f.LocalFilename, err)
+		return false
+	}
+
+	result := analyzeSource(f, output)
+
+	log.Printf("Remuxing %q to %q\n", *f.LocalFilename, output)
+	remux, err := mpegts.RemuxFile(*f.LocalFilename, output, tsStreamFilter(programs))
+	if err != nil {
+		log.Printf("Failed to remux %q: %v\n", *f.LocalFilename, err)
+		return false
+	}
+	for _, s := range remux.Streams {
+		log.Printf("Kept PID 0x%04X %s %s\n", s.Pid, s.Codec, normalizeLanguage(s.Language))
+	}
+	log.Printf("Wrote %d packets, dropped %d\n", remux.Packets, remux.Dropped)
+
+	var duration time.Duration
+	stats, err := mpegts.ScanFile(output)
+	if err == nil {
+		duration = stats.Duration
+	}
+	checkArchivedDuration(result, duration, err)
+
+	if err = writeArchiveResult(result); err != nil {
+		log.Printf("Unable to write archive result for %q: %v\n", output, err)
+	}
+
+	return true
+}
@@ -0,0 +1,75 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/cobra"
+)
+
+var repairCmd = &cobra.Command{
+	Use:   "repair FILE OUTPUT",
+	Short: "Repair a damaged recording",
+	Long: `Copy a recording, resyncing after garbage and dropping packets with
+transport errors and broken PES packets.`,
+	Args: cobra.ExactArgs(2),
+	Run:  repairMain,
+}
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+}
+
+func repairMain(cmd *cobra.Command, args []string) {
+	result, err := mpegts.RepairFile(args[0], args[1])
+	if err != nil {
+		log.Fatalf("Unable to repair %q: %v", args[0], err)
+	}
+
+	log.Printf("Repaired %q: %v\n", args[0], result)
+}
+
+// execRepaired runs mkvcmd again on a repaired copy of filename, written to
+// dir next to the archive.
+func execRepaired(mkvcmd *mkvmerge.MkvMerge, filename, dir string) error {
+	tmp, err := ioutil.TempFile(dir, ".repair-*.ts")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	result, err := mpegts.RepairFile(filename, tmp.Name())
+	if err != nil {
+		return err
+	}
+	log.Printf("Repaired %q: %v\n", filename, result)
+
+	mkvcmd.Close()
+	mkvcmd.SetInput(tmp.Name())
+
+	if err = mkvcmd.Exec(); !mkvmerge.Succeeded(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var searchTranscriptsCmd = &cobra.Command{
+	Use:   "search-transcripts PHRASE",
+	Short: "Search the transcripts of recordings",
+	Long: `Find recordings whose closed captions contain the phrase, with the time
+offset of each match.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  searchTranscriptsMain,
+}
+
+func init() {
+	rootCmd.AddCommand(searchTranscriptsCmd)
+
+	searchTranscriptsCmd.Flags().StringVarP(&transcriptIndex, "transcript-index", "", "", "Transcript index file (default is $HOME/.hdhrdvrutil-transcripts.json)")
+}
+
+func formatOffset(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", d/time.Hour, d/time.Minute%60, d/time.Second%60)
+}
+
+func searchTranscriptsMain(cmd *cobra.Command, args []string) {
+	idx, _ := loadTranscriptIndex()
+
+	matches := idx.Search(strings.Join(args, " "))
+	if len(matches) == 0 {
+		fmt.Println("No matches found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Recording\tProgram ID\tOffset\tText\t")
+	for _, m := range matches {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", m.Document.Path, m.Document.ProgramID, formatOffset(m.Time),
+			strings.Replace(m.Text, "\n", " ", -1))
+	}
+	w.Flush()
+}
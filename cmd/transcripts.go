@@ -0,0 +1,144 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/captions"
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/transcript"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var indexTranscriptsCmd = &cobra.Command{
+	Use:   "index-transcripts FILE...",
+	Short: "Add recordings to the transcript index",
+	Long: `Extract the closed caption text of each recording and add it to the
+transcript index used by search-transcripts.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  indexTranscriptsMain,
+}
+
+var (
+	indexTranscripts = false
+	transcriptIndex  = ""
+
+	// The transcript index archive adds to, saved once all recordings are
+	// archived.
+	archiveTranscripts *transcript.Index
+)
+
+func init() {
+	rootCmd.AddCommand(indexTranscriptsCmd)
+
+	indexTranscriptsCmd.Flags().StringVarP(&transcriptIndex, "transcript-index", "", "", "Transcript index file (default is $HOME/.hdhrdvrutil-transcripts.json)")
+	archiveCmd.Flags().StringVarP(&transcriptIndex, "transcript-index", "", "", "Transcript index file (default is $HOME/.hdhrdvrutil-transcripts.json)")
+	archiveCmd.Flags().BoolVarP(&indexTranscripts, "transcripts", "", false, "Add the closed caption text to the transcript index")
+}
+
+// transcriptIndexPath returns the index file from the command line, the
+// config file or the default in the home directory, in that order.
+func transcriptIndexPath() (string, error) {
+	if transcriptIndex != "" {
+		return transcriptIndex, nil
+	}
+	if p := viper.GetString("transcript-index"); p != "" {
+		return p, nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("Unable to find home directory: %v", err)
+	}
+
+	return filepath.Join(home, ".hdhrdvrutil-transcripts.json"), nil
+}
+
+// loadTranscriptIndex loads the transcript index, exiting if it can't be
+// read. It returns the index and its file name.
+func loadTranscriptIndex() (*transcript.Index, string) {
+	filename, err := transcriptIndexPath()
+	if err != nil {
+		log.Fatalf("Unable to locate transcript index: %v", err)
+	}
+
+	idx, err := transcript.Load(filename)
+	if err != nil {
+		log.Fatalf("Unable to load transcript index %q: %v", filename, err)
+	}
+
+	return idx, filename
+}
+
+// transcriptDocument builds the transcript of a recording archived to path.
+// Offsets are moved onto the timeline of the parts kept in the archive.
+func transcriptDocument(r *hdhomerun.Recording, path string, cues []captions.Cue, parts []mkvmerge.Part) *transcript.Document {
+	doc := &transcript.Document{Path: path, Indexed: time.Now()}
+	if r.ProgramID != nil {
+		doc.ProgramID = *r.ProgramID
+	}
+	if r.Title != nil {
+		doc.Title = *r.Title
+		if r.EpisodeTitle != nil {
+			doc.Title += " - " + *r.EpisodeTitle
+		}
+	}
+
+	for _, c := range cues {
+		if t, ok := shiftTime(c.Start, parts); ok {
+			doc.Lines = append(doc.Lines, transcript.Line{Time: t, Text: c.Text})
+		}
+	}
+
+	return doc
+}
+
+func indexTranscriptsMain(cmd *cobra.Command, args []string) {
+	idx, filename := loadTranscriptIndex()
+
+	for _, arg := range args {
+		path, err := filepath.Abs(arg)
+		if err != nil {
+			log.Printf("Unable to construct absolute path %q: %v\n", arg, err)
+			continue
+		}
+
+		meta := &hdhomerun.RecordingFile{Filename: &path}
+		if err := meta.Parse(); err != nil {
+			log.Printf("No metadata in %q: %v\n", path, err)
+		}
+
+		cues := recordingCaptions(path)
+		if len(cues) == 0 {
+			log.Printf("No captions in %q\n", path)
+			continue
+		}
+
+		idx.Add(transcriptDocument((*hdhomerun.Recording)(meta), path, cues, []mkvmerge.Part{{}}))
+	}
+
+	if err := idx.Save(filename); err != nil {
+		log.Fatalf("Unable to save transcript index %q: %v", filename, err)
+	}
+}
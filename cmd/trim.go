@@ -0,0 +1,89 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+)
+
+// Broadcast times further than this from the record start time are taken to
+// be wrong.
+const maxClockSkew = 5 * time.Minute
+
+var (
+	trimPadding = false
+	trimMargin  = 30 * time.Second
+)
+
+func init() {
+	archiveCmd.Flags().BoolVarP(&trimPadding, "trim-padding", "", false, "Remove the recording padding before and after the program")
+	archiveCmd.Flags().DurationVarP(&trimMargin, "trim-margin", "", trimMargin, "Padding to keep when trimming")
+}
+
+// streamStart returns the broadcast time at the start of the stream of r, or
+// the zero time if it isn't known or doesn't match the record start time.
+func streamStart(r *hdhomerun.Recording) time.Time {
+	start, err := mpegts.ReadStreamStartFile(*r.LocalFilename)
+	if err != nil {
+		log.Printf("Unable to read the broadcast time of %q: %v\n", *r.LocalFilename, err)
+		return time.Time{}
+	}
+	if r.RecordStartTime != nil {
+		if skew := start.Sub(time.Unix(*r.RecordStartTime, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+			log.Printf("Broadcast time of %q is %v off the record start time, ignoring it\n", *r.LocalFilename, skew)
+			return time.Time{}
+		}
+	}
+	return start
+}
+
+// programPart returns the part of the recording between the scheduled start
+// and end of the program, plus the safety margin. stream is the broadcast time
+// at the start of the stream, zero if unknown. duration is the length of the
+// stream.
+func programPart(r *hdhomerun.Recording, stream time.Time, duration time.Duration) (mkvmerge.Part, bool) {
+	start, end, ok := r.ScheduledOffsets()
+	if !ok || duration <= 0 {
+		return mkvmerge.Part{}, false
+	}
+
+	// The stream starts a moment after the record start time while the tuner
+	// locks, shift the offsets onto the stream clock.
+	if !stream.IsZero() {
+		lead := stream.Sub(time.Unix(*r.RecordStartTime, 0))
+		start -= lead
+		end -= lead
+	}
+
+	start -= trimMargin
+	end += trimMargin
+	if start < 0 {
+		start = 0
+	}
+	if end >= duration {
+		end = 0
+	}
+	if start == 0 && end == 0 {
+		return mkvmerge.Part{}, false
+	}
+
+	return mkvmerge.Part{Start: start, End: end}, true
+}
@@ -0,0 +1,57 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+)
+
+func TestProgramPart(t *testing.T) {
+	// Recorded from 970 to 4630, the program airs from 1000 to 4600.
+	r := testRecording("a", "5.1", 970, 4630)
+
+	tests := []struct {
+		name     string
+		stream   time.Time
+		duration time.Duration
+		part     mkvmerge.Part
+		ok       bool
+	}{
+		{"record start time", time.Time{}, time.Hour + 2*time.Minute, mkvmerge.Part{Start: 20 * time.Second, End: 3640 * time.Second}, true},
+		{"stream clock", time.Unix(975, 0), time.Hour + 2*time.Minute, mkvmerge.Part{Start: 15 * time.Second, End: 3635 * time.Second}, true},
+		{"late stream", time.Unix(995, 0), time.Hour + 2*time.Minute, mkvmerge.Part{Start: 0, End: 3615 * time.Second}, true},
+		{"short stream", time.Unix(975, 0), time.Hour, mkvmerge.Part{Start: 15 * time.Second}, true},
+		{"nothing to trim", time.Unix(995, 0), time.Hour, mkvmerge.Part{}, false},
+		{"no duration", time.Time{}, 0, mkvmerge.Part{}, false},
+	}
+
+	trimMargin = 10 * time.Second
+	defer func() { trimMargin = 30 * time.Second }()
+	for _, tt := range tests {
+		part, ok := programPart(r, tt.stream, tt.duration)
+		if ok != tt.ok || (ok && part != tt.part) {
+			t.Errorf("%s: got %v %v, want %v %v", tt.name, part, ok, tt.part, tt.ok)
+		}
+	}
+
+	if _, ok := programPart(&hdhomerun.Recording{}, time.Time{}, time.Hour); ok {
+		t.Errorf("programPart of a recording without times succeeded")
+	}
+}
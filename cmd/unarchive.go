@@ -0,0 +1,148 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/saintdev/hdhrdvrutil/ffmpeg"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+
+	"github.com/spf13/cobra"
+)
+
+var unarchiveCmd = &cobra.Command{
+	Use:   "unarchive FILE... SRC",
+	Short: "Restore archived recordings",
+	Long: `Convert archived MKV files back into recordings in srcdir that can be
+played by the HDHomeRun DVR service.`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  unarchiveMain,
+}
+
+func init() {
+	rootCmd.AddCommand(unarchiveCmd)
+}
+
+// tagMetadata rebuilds the HDHomeRun metadata from the Matroska tags written
+// by copyToMkv. Files tagged by other tools may carry the series title at the
+// collection level, with the episode title below it.
+func tagMetadata(tags *mkvmerge.Tags) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+
+	series, episodic := tags.Get(mkvmerge.Collection, "TITLE")
+	title, ok := tags.Get(mkvmerge.Episode, "TITLE")
+	switch {
+	case episodic:
+		fields["Title"] = series
+		if ok {
+			fields["EpisodeTitle"] = title
+		}
+	case ok:
+		fields["Title"] = title
+	default:
+		return nil, fmt.Errorf("No TITLE tag")
+	}
+
+	if s, ok := tags.Get(mkvmerge.Episode, "SUBTITLE"); ok {
+		fields["EpisodeTitle"] = s
+		episodic = true
+	}
+	if s, ok := tags.Get(mkvmerge.Episode, "SYNOPSIS"); ok {
+		fields["Synopsis"] = s
+	}
+
+	if s, ok := tags.Get(mkvmerge.Episode, "PART_NUMBER"); ok {
+		episode, _ := strconv.Atoi(s)
+		season := 0
+		if s, ok := tags.Get(mkvmerge.Season, "PART_NUMBER"); ok {
+			season, _ = strconv.Atoi(s)
+		}
+		fields["EpisodeNumber"] = fmt.Sprintf("S%02dE%02d", season, episode)
+		episodic = true
+	}
+
+	// Without any episode information it's a movie.
+	fields["Category"] = "movie"
+	if episodic {
+		fields["Category"] = "series"
+	}
+
+	return fields, nil
+}
+
+func unarchiveFile(filename, dest string) error {
+	tags, err := mkvmerge.ExtractTags(filename)
+	if err != nil {
+		return err
+	}
+
+	fields, err := tagMetadata(tags)
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile("", filepath.Base(os.Args[0]))
+	if err != nil {
+		return err
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	ffcmd := ffmpeg.New()
+	ffcmd.SetInput(filename)
+	ffcmd.SetOutput(tempFile.Name())
+	ffcmd.SetFormat("mpegts")
+
+	if err = ffcmd.Exec(); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	output, err := writeRecording(tempFile.Name(), dest, fields, info.ModTime())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Restored %q as %q\n", filename, output)
+
+	return nil
+}
+
+func unarchiveMain(cmd *cobra.Command, args []string) {
+	srcDir = absDir(args[len(args)-1])
+
+	failed := false
+	for _, filename := range args[:len(args)-1] {
+		if err := unarchiveFile(filename, srcDir); err != nil {
+			log.Printf("Failed to unarchive %q: %v\n", filename, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
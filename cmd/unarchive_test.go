@@ -0,0 +1,94 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+)
+
+func simpleTags(pairs ...string) []mkvmerge.SimpleTag {
+	var tags []mkvmerge.SimpleTag
+	for i := 0; i+1 < len(pairs); i += 2 {
+		tags = append(tags, mkvmerge.SimpleTag{Name: pairs[i], String: pairs[i+1]})
+	}
+	return tags
+}
+
+func TestTagMetadata(t *testing.T) {
+	season := &mkvmerge.Target{TargetTypeValue: mkvmerge.Season}
+	collection := &mkvmerge.Target{TargetTypeValue: mkvmerge.Collection}
+	track := &mkvmerge.Target{TrackUIDs: []uint{1234}}
+
+	tests := []struct {
+		name   string
+		tags   []mkvmerge.Tag
+		fields map[string]interface{}
+	}{
+		{"episode", []mkvmerge.Tag{
+			{SimpleTags: simpleTags("TITLE", "The Show", "SUBTITLE", "The Pilot", "SYNOPSIS", "It begins.", "PART_NUMBER", "2")},
+			{Target: season, SimpleTags: simpleTags("PART_NUMBER", "1")},
+		}, map[string]interface{}{
+			"Title": "The Show", "EpisodeTitle": "The Pilot", "Synopsis": "It begins.", "EpisodeNumber": "S01E02", "Category": "series",
+		}},
+		// An episode title alone makes it a series.
+		{"unnumbered episode", []mkvmerge.Tag{
+			{SimpleTags: simpleTags("TITLE", "The Show", "SUBTITLE", "Part One")},
+		}, map[string]interface{}{
+			"Title": "The Show", "EpisodeTitle": "Part One", "Category": "series",
+		}},
+		{"series title", []mkvmerge.Tag{
+			{Target: collection, SimpleTags: simpleTags("TITLE", "The Show")},
+			{SimpleTags: simpleTags("TITLE", "The Pilot")},
+		}, map[string]interface{}{
+			"Title": "The Show", "EpisodeTitle": "The Pilot", "Category": "series",
+		}},
+		{"movie", []mkvmerge.Tag{
+			{SimpleTags: simpleTags("TITLE", "The Film", "SYNOPSIS", "Things happen.")},
+		}, map[string]interface{}{
+			"Title": "The Film", "Synopsis": "Things happen.", "Category": "movie",
+		}},
+		// Track tags don't describe the recording.
+		{"track title", []mkvmerge.Tag{
+			{Target: track, SimpleTags: simpleTags("TITLE", "English", "SUBTITLE", "Commentary")},
+			{SimpleTags: simpleTags("TITLE", "The Film")},
+		}, map[string]interface{}{
+			"Title": "The Film", "Category": "movie",
+		}},
+		{"no title", []mkvmerge.Tag{
+			{Target: track, SimpleTags: simpleTags("TITLE", "English")},
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		fields, err := tagMetadata(&mkvmerge.Tags{Tags: tt.tags})
+		if tt.fields == nil {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", tt.name, fields)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("%s: got %v, want %v", tt.name, fields, tt.fields)
+		}
+	}
+}
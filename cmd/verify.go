@@ -0,0 +1,116 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify SRC",
+	Short: "Find failed or truncated recordings",
+	Long: `Check the recordings in srcdir for signs of a failed or truncated recording
+and optionally ask the DVR to record them again at the next airing.`,
+	Args: cobra.ExactArgs(1),
+	Run:  verifyMain,
+}
+
+var (
+	requeue          = false
+	minDurationRatio = 0.95
+	maxGap           = 2 * time.Second
+	maxCCErrorRate   = 0.0001
+)
+
+func init() {
+	recordingsCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().BoolVarP(&requeue, "requeue", "", false, "Delete suspect recordings and re-record them at the next airing")
+	verifyCmd.Flags().Float64VarP(&minDurationRatio, "min-duration", "", minDurationRatio, "Minimum ratio of recorded to scheduled duration")
+	verifyCmd.Flags().DurationVarP(&maxGap, "max-gap", "", maxGap, "Maximum gap in stream timestamps")
+	verifyCmd.Flags().Float64VarP(&maxCCErrorRate, "max-cc-errors", "", maxCCErrorRate, "Maximum ratio of continuity errors to packets")
+}
+
+// verifyRecording returns the signs of a truncated or damaged recording in
+// the stats of its stream.
+func verifyRecording(r *hdhomerun.Recording, stats *mpegts.Stats) []string {
+	var problems []string
+
+	if scheduled := r.ScheduledDuration(); scheduled > 0 {
+		if ratio := stats.Duration.Seconds() / scheduled.Seconds(); ratio < minDurationRatio {
+			problems = append(problems, fmt.Sprintf("duration %v of %v scheduled", stats.Duration.Round(time.Second), scheduled))
+		}
+	}
+	if stats.MaxGap > maxGap {
+		problems = append(problems, fmt.Sprintf("%v gap in timestamps", stats.MaxGap.Round(time.Millisecond)))
+	}
+	if rate := stats.ContinuityErrorRate(); rate > maxCCErrorRate {
+		problems = append(problems, fmt.Sprintf("%d continuity errors in %d packets", stats.ContinuityErrors, stats.Packets))
+	}
+
+	return problems
+}
+
+func verifyMain(cmd *cobra.Command, args []string) {
+	srcDir = absDir(args[0])
+
+	dvrClient := hdhomerun.NewClient(nil)
+	recordings := fetchRecordings(dvrClient)
+
+	scanRecordings(dvrClient, srcDir, recordings)
+
+	suspect := 0
+	for _, r := range recordings {
+		if r.LocalFilename == nil {
+			continue
+		}
+
+		var problems, damaged []string
+		if !r.Succeeded() {
+			problems = append(problems, "record engine reported failure")
+		}
+		if stats, err := mpegts.ScanFile(*r.LocalFilename); err != nil {
+			problems = append(problems, fmt.Sprintf("unable to read file: %v", err))
+		} else {
+			damaged = verifyRecording(r, stats)
+			problems = append(problems, damaged...)
+		}
+		if len(problems) == 0 {
+			continue
+		}
+		suspect++
+
+		fmt.Printf("%s: %s\n", *r.LocalFilename, strings.Join(problems, ", "))
+
+		// Only a stream we could read and found damaged is worth recording
+		// again, not one we failed to read.
+		if requeue && len(damaged) > 0 {
+			if err := dvrClient.Recordings.Delete(r, true); err != nil {
+				log.Printf("Failed to re-queue recording %q: %v\n", *r.LocalFilename, err)
+			}
+		}
+	}
+
+	log.Printf("%d of %d recordings suspect\n", suspect, len(recordings))
+}
@@ -0,0 +1,56 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+)
+
+func TestVerifyRecording(t *testing.T) {
+	scheduled := testRecording("a", "5.1", 970, 4630)
+	unscheduled := &hdhomerun.Recording{}
+
+	tests := []struct {
+		name      string
+		recording *hdhomerun.Recording
+		stats     mpegts.Stats
+		problems  []string
+	}{
+		{"healthy", scheduled, mpegts.Stats{Packets: 10000000, ContinuityErrors: 10, Duration: 3660 * time.Second, MaxGap: 100 * time.Millisecond}, nil},
+		{"padding lost", scheduled, mpegts.Stats{Packets: 10000000, Duration: 3500 * time.Second}, nil},
+		{"truncated", scheduled, mpegts.Stats{Packets: 5000000, Duration: 30 * time.Minute},
+			[]string{"duration 30m0s of 1h0m0s scheduled"}},
+		{"gap", scheduled, mpegts.Stats{Packets: 10000000, Duration: time.Hour, MaxGap: 4200 * time.Millisecond},
+			[]string{"4.2s gap in timestamps"}},
+		{"continuity errors", scheduled, mpegts.Stats{Packets: 1000000, ContinuityErrors: 5000, Duration: time.Hour},
+			[]string{"5000 continuity errors in 1000000 packets"}},
+		{"truncated and damaged", scheduled, mpegts.Stats{Packets: 100000, ContinuityErrors: 100, Duration: 10 * time.Minute, MaxGap: 30 * time.Second},
+			[]string{"duration 10m0s of 1h0m0s scheduled", "30s gap in timestamps", "100 continuity errors in 100000 packets"}},
+		{"unknown schedule", unscheduled, mpegts.Stats{Packets: 1000, Duration: time.Minute}, nil},
+		{"empty", unscheduled, mpegts.Stats{}, nil},
+	}
+
+	for _, tt := range tests {
+		if problems := verifyRecording(tt.recording, &tt.stats); !reflect.DeepEqual(problems, tt.problems) {
+			t.Errorf("%s: got %q, want %q", tt.name, problems, tt.problems)
+		}
+	}
+}
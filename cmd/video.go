@@ -0,0 +1,131 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"strconv"
+	"text/template"
+
+	"github.com/saintdev/hdhrdvrutil/hdhomerun"
+	"github.com/saintdev/hdhrdvrutil/mkvmerge"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
+
+	"github.com/gosimple/slug"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	archiveCmd.Flags().StringP("name-template", "", "", "Template for archive file names, e.g. \"{{.Name}}-{{.Resolution}}\"")
+
+	viper.BindPFlag("name-template", archiveCmd.Flags().Lookup("name-template"))
+}
+
+// nameFields are the variables available to the name template.
+type nameFields struct {
+	Name         string
+	Title        string
+	EpisodeTitle string
+	Season       int
+	Episode      int
+	ProgramID    string
+	Resolution   string
+	VideoCodec   string
+	Width        int
+	Height       int
+	FrameRate    string
+	Aspect       string
+}
+
+// The name template, parsed once when archiving starts.
+var archiveTemplate *template.Template
+
+func nameTemplate() *template.Template {
+	text := viper.GetString("name-template")
+	if text == "" {
+		return nil
+	}
+
+	tmpl, err := template.New("name").Parse(text)
+	if err != nil {
+		log.Fatalf("Invalid name template %q: %v", text, err)
+	}
+
+	return tmpl
+}
+
+func readVideoInfo(filename string) *mpegts.VideoInfo {
+	video, err := mpegts.ReadVideoInfoFile(filename)
+	if err != nil {
+		log.Printf("Unable to read video format of %q: %v\n", filename, err)
+		return nil
+	}
+	return video
+}
+
+func formatFrameRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// templateName names the archive of f using the name template, or returns
+// the default name if there is no template.
+func templateName(f *hdhomerun.Recording, name string, video *mpegts.VideoInfo) string {
+	tmpl := archiveTemplate
+	if tmpl == nil {
+		return name
+	}
+
+	fields := nameFields{
+		Name:    name,
+		Title:   *f.Title,
+		Season:  f.Season,
+		Episode: f.Episode,
+	}
+	if f.EpisodeTitle != nil {
+		fields.EpisodeTitle = *f.EpisodeTitle
+	}
+	if f.ProgramID != nil {
+		fields.ProgramID = *f.ProgramID
+	}
+	if video != nil {
+		fields.Resolution = video.Resolution()
+		fields.VideoCodec = video.Codec
+		fields.Width = video.Width
+		fields.Height = video.Height
+		fields.FrameRate = formatFrameRate(video.FrameRate)
+		fields.Aspect = video.Aspect()
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, fields); err != nil {
+		log.Printf("Unable to apply name template to %q: %v\n", name, err)
+		return name
+	}
+
+	return slug.Make(buf.String())
+}
+
+func setVideoTags(mkvcmd *mkvmerge.MkvMerge, video *mpegts.VideoInfo) {
+	mkvcmd.SetTag("RESOLUTION", video.Resolution())
+	mkvcmd.SetTag("VIDEO_CODEC", video.Codec)
+	if video.FrameRate > 0 {
+		mkvcmd.SetTag("FRAME_RATE", formatFrameRate(video.FrameRate))
+	}
+	if aspect := video.Aspect(); aspect != "" {
+		mkvcmd.SetTag("ASPECT_RATIO", aspect)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package comskip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/edl"
+)
+
+type Comskip struct {
+	stdout    io.Writer
+	stderr    io.Writer
+	command   string
+	ini       string
+	input     string
+	outputDir string
+	Quiet     bool
+}
+
+func New() *Comskip {
+	c := new(Comskip)
+
+	c.stdout = os.Stdout
+	c.stderr = os.Stderr
+	c.command = "comskip"
+
+	c.Quiet = true
+
+	return c
+}
+
+func (c *Comskip) SetStdout(w io.Writer) {
+	c.stdout = w
+}
+
+func (c *Comskip) SetStderr(w io.Writer) {
+	c.stderr = w
+}
+
+// SetCommand sets the name or path of a comskip compatible detector.
+func (c *Comskip) SetCommand(command string) {
+	c.command = command
+}
+
+func (c *Comskip) SetIni(ini string) {
+	c.ini = ini
+}
+
+func (c *Comskip) SetInput(input string) {
+	c.input = input
+}
+
+func (c *Comskip) SetOutputDir(dir string) {
+	c.outputDir = dir
+}
+
+func (c *Comskip) args() []string {
+	var args []string
+
+	if c.Quiet {
+		args = append(args, "--quiet")
+	}
+
+	if c.ini != "" {
+		args = append(args, "--ini="+c.ini)
+	}
+
+	if c.outputDir != "" {
+		args = append(args, "--output="+c.outputDir)
+	}
+
+	return append(args, c.input)
+}
+
+// Exec runs the detector. Comskip exits with 1 when it found commercials,
+// which is not an error.
+func (c *Comskip) Exec() error {
+	command, err := exec.LookPath(c.command)
+	if err != nil {
+		return err
+	}
+
+	args := c.args()
+	cmd := exec.Command(command, args...)
+
+	cmd.Stderr = c.stderr
+	cmd.Stdout = c.stdout
+
+	log.Printf("%s %s", command, strings.Join(args, " "))
+
+	err = cmd.Run()
+	if exitError, ok := err.(*exec.ExitError); ok {
+		if exitError.Sys().(syscall.WaitStatus).ExitStatus() == 1 {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (c *Comskip) outputBase() string {
+	dir := c.outputDir
+	if dir == "" {
+		dir = filepath.Dir(c.input)
+	}
+	base := filepath.Base(c.input)
+
+	return filepath.Join(dir, strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// Breaks reads the commercial breaks found by Exec, from the EDL output if
+// it was enabled in the ini file, otherwise from the default txt output.
+func (c *Comskip) Breaks() ([]edl.Entry, error) {
+	base := c.outputBase()
+
+	entries, err := edl.ReadFile(base + ".edl")
+	if err == nil {
+		for i := range entries {
+			entries[i].Action = edl.Commercial
+		}
+		return entries, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.Open(base + ".txt")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readTxt(file)
+}
+
+// readTxt parses the comskip txt output, a header with the frame rate in
+// hundredths followed by start and end frames of each break.
+func readTxt(r io.Reader) ([]edl.Entry, error) {
+	var (
+		entries []edl.Entry
+		rate    float64
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if rate == 0 {
+			var frames, hundredths int
+			if _, err := fmt.Sscanf(line, "FILE PROCESSING COMPLETE %d FRAMES AT %d", &frames, &hundredths); err == nil {
+				rate = float64(hundredths) / 100
+			}
+			continue
+		}
+
+		var start, end int64
+		if _, err := fmt.Sscanf(line, "%d %d", &start, &end); err != nil {
+			continue
+		}
+		entries = append(entries, edl.Entry{
+			Start:  time.Duration(float64(start) / rate * float64(time.Second)),
+			End:    time.Duration(float64(end) / rate * float64(time.Second)),
+			Action: edl.Commercial,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if rate == 0 {
+		return nil, fmt.Errorf("No frame rate in comskip output")
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,165 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package comskip
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintdev/hdhrdvrutil/edl"
+)
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		quiet  bool
+		ini    string
+		output string
+		want   []string
+	}{
+		{true, "", "", []string{"--quiet", "in.ts"}},
+		{false, "/etc/comskip.ini", "", []string{"--ini=/etc/comskip.ini", "in.ts"}},
+		{true, "comskip.ini", "/tmp/out", []string{"--quiet", "--ini=comskip.ini", "--output=/tmp/out", "in.ts"}},
+	}
+
+	for _, tt := range tests {
+		c := New()
+		c.Quiet = tt.quiet
+		c.SetIni(tt.ini)
+		c.SetOutputDir(tt.output)
+		c.SetInput("in.ts")
+		if got := c.args(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("args() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+const testTxt = `FILE PROCESSING COMPLETE  9000 FRAMES AT  2500
+-------------------
+25	750
+1500	3000
+`
+
+func TestReadTxt(t *testing.T) {
+	tests := []struct {
+		name    string
+		txt     string
+		entries []edl.Entry
+		err     bool
+	}{
+		{"breaks", testTxt, []edl.Entry{
+			{Start: time.Second, End: 30 * time.Second, Action: edl.Commercial},
+			{Start: time.Minute, End: 2 * time.Minute, Action: edl.Commercial},
+		}, false},
+		{"NTSC", "FILE PROCESSING COMPLETE  53999 FRAMES AT  2997\n-------------------\n0 2997\n", []edl.Entry{
+			{Start: 0, End: 100 * time.Second, Action: edl.Commercial},
+		}, false},
+		{"no breaks", "FILE PROCESSING COMPLETE  9000 FRAMES AT  2500\n-------------------\n", nil, false},
+		{"no header", "25 750\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		entries, err := readTxt(strings.NewReader(tt.txt))
+		if (err != nil) != tt.err || !reflect.DeepEqual(entries, tt.entries) {
+			t.Errorf("%s: got %v %v, want %v", tt.name, entries, err, tt.entries)
+		}
+	}
+}
+
+// fakeComskip writes a detector to dir that copies output to the output
+// directory, named after the input, and exits with status.
+func fakeComskip(t *testing.T, dir, ext, output string, status int) string {
+	fixture := filepath.Join(dir, "fixture")
+	if err := ioutil.WriteFile(fixture, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	script := "#!/bin/sh\n" +
+		"for a; do case \"$a\" in --output=*) out=\"${a#--output=}\";; esac; input=\"$a\"; done\n"
+	if ext != "" {
+		script += fmt.Sprintf("cp %q \"$out/$(basename \"${input%%.*}\").%s\"\n", fixture, ext)
+	}
+	script += fmt.Sprintf("exit %d\n", status)
+
+	command := filepath.Join(dir, "comskip")
+	if err := ioutil.WriteFile(command, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return command
+}
+
+func TestExecBreaks(t *testing.T) {
+	breaks := []edl.Entry{
+		{Start: time.Second, End: 30 * time.Second, Action: edl.Commercial},
+		{Start: time.Minute, End: 2 * time.Minute, Action: edl.Commercial},
+	}
+
+	tests := []struct {
+		name    string
+		ext     string
+		output  string
+		status  int
+		execErr bool
+		entries []edl.Entry
+	}{
+		// Comskip exits with 1 when it found commercials.
+		{"txt", "txt", testTxt, 1, false, breaks},
+		{"edl", "edl", "1.00\t30.00\t0\n60.00\t120.00\t3\n", 0, false, breaks},
+		{"failed", "", "", 2, true, nil},
+		{"no output", "", "", 0, false, nil},
+	}
+
+	dir, err := ioutil.TempDir("", "comskip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range tests {
+		output := filepath.Join(dir, tt.name)
+		if err := os.Mkdir(output, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		c := New()
+		c.SetCommand(fakeComskip(t, dir, tt.ext, tt.output, tt.status))
+		c.SetInput(filepath.Join(dir, "recording.ts"))
+		c.SetOutputDir(output)
+
+		if err := c.Exec(); (err != nil) != tt.execErr {
+			t.Errorf("%s: Exec() = %v", tt.name, err)
+		}
+		if tt.execErr {
+			continue
+		}
+
+		entries, err := c.Breaks()
+		if tt.entries == nil {
+			if err == nil {
+				t.Errorf("%s: Breaks() = %v, want an error", tt.name, entries)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(entries, tt.entries) {
+			t.Errorf("%s: Breaks() = %v %v, want %v", tt.name, entries, err, tt.entries)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package edl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	Kodi Format = iota
+	MPlayer
+)
+
+// Actions as defined by Kodi, MPlayer only knows Cut and Mute.
+type Action int
+
+const (
+	Cut        Action = 0
+	Mute       Action = 1
+	Scene      Action = 2
+	Commercial Action = 3
+)
+
+type Entry struct {
+	Start  time.Duration
+	End    time.Duration
+	Action Action
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "kodi":
+		return Kodi, nil
+	case "mplayer":
+		return MPlayer, nil
+	}
+	return 0, fmt.Errorf("Unknown EDL format %q", s)
+}
+
+func (f Format) action(a Action) Action {
+	if f == MPlayer && a != Mute {
+		return Cut
+	}
+	return a
+}
+
+func Write(w io.Writer, entries []Entry, format Format) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		fmt.Fprintf(bw, "%.2f\t%.2f\t%d\n", e.Start.Seconds(), e.End.Seconds(), format.action(e.Action))
+	}
+
+	return bw.Flush()
+}
+
+func WriteFile(filename string, entries []Entry, format Format) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = Write(file, entries, format)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// parseTime parses seconds or a [[HH:]MM:]SS.sss timecode.
+func parseTime(s string) (time.Duration, error) {
+	var d float64
+	for _, field := range strings.Split(s, ":") {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid EDL time %q", s)
+		}
+		d = d*60 + v
+	}
+	return time.Duration(d * float64(time.Second)), nil
+}
+
+// Read parses an EDL file. Entries without an action are cuts.
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Invalid EDL line %q", scanner.Text())
+		}
+
+		start, err := parseTime(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseTime(fields[1])
+		if err != nil {
+			return nil, err
+		}
+
+		e := Entry{Start: start, End: end, Action: Cut}
+		if len(fields) > 2 {
+			a, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid EDL action %q", fields[2])
+			}
+			e.Action = Action(a)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+func ReadFile(filename string) ([]Entry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// ParseRange parses a "start-end" time range into a cut.
+func ParseRange(s string) (Entry, error) {
+	i := strings.Index(s, "-")
+	if i < 0 {
+		return Entry{}, fmt.Errorf("Invalid time range %q", s)
+	}
+
+	start, err := parseTime(s[:i])
+	if err != nil {
+		return Entry{}, err
+	}
+	end, err := parseTime(s[i+1:])
+	if err != nil {
+		return Entry{}, err
+	}
+	if end <= start {
+		return Entry{}, fmt.Errorf("Invalid time range %q", s)
+	}
+
+	return Entry{Start: start, End: end, Action: Cut}, nil
+}
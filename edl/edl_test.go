@@ -0,0 +1,105 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package edl
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEntries = []Entry{
+	{Start: 90 * time.Second, End: 210*time.Second + 500*time.Millisecond, Action: Commercial},
+	{Start: 20 * time.Minute, End: 20*time.Minute + 5*time.Second, Action: Mute},
+	{Start: time.Hour, End: time.Hour + 250*time.Millisecond, Action: Cut},
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{Kodi, "90.00\t210.50\t3\n1200.00\t1205.00\t1\n3600.00\t3600.25\t0\n"},
+		{MPlayer, "90.00\t210.50\t0\n1200.00\t1205.00\t1\n3600.00\t3600.25\t0\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, testEntries, tt.format); err != nil {
+			t.Errorf("Write(%d): %v", tt.format, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Write(%d) = %q, want %q", tt.format, got, tt.want)
+		}
+
+		entries, err := Read(&buf)
+		if err != nil {
+			t.Errorf("Read(Write(%d)): %v", tt.format, err)
+		}
+		for i, e := range entries {
+			if want := testEntries[i]; e.Start != want.Start || e.End != want.End || e.Action != tt.format.action(want.Action) {
+				t.Errorf("Read(Write(%d))[%d] = %v, want %v", tt.format, i, e, want)
+			}
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		in      string
+		entries []Entry
+		ok      bool
+	}{
+		{"", nil, true},
+		{"# comskip\n\n12.5 30 3\n", []Entry{{12500 * time.Millisecond, 30 * time.Second, Commercial}}, true},
+		{"1:30  3:30.5\n", []Entry{{90 * time.Second, 210500 * time.Millisecond, Cut}}, true},
+		{"01:00:00.000\t01:00:10.000\t2\n", []Entry{{time.Hour, time.Hour + 10*time.Second, Scene}}, true},
+		{"12.5\n", nil, false},
+		{"a b\n", nil, false},
+		{"1 2 cut\n", nil, false},
+	}
+
+	for _, tt := range tests {
+		entries, err := Read(strings.NewReader(tt.in))
+		if (err == nil) != tt.ok || !reflect.DeepEqual(entries, tt.entries) {
+			t.Errorf("Read(%q) = %v %v, want %v", tt.in, entries, err, tt.entries)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		in    string
+		entry Entry
+		ok    bool
+	}{
+		{"0-90", Entry{0, 90 * time.Second, Cut}, true},
+		{"10:00-12:30.5", Entry{10 * time.Minute, 12*time.Minute + 30500*time.Millisecond, Cut}, true},
+		{"1:00:00-1:02:00", Entry{time.Hour, time.Hour + 2*time.Minute, Cut}, true},
+		{"90", Entry{}, false},
+		{"90-30", Entry{}, false},
+		{"1:00-x", Entry{}, false},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseRange(tt.in)
+		if (err == nil) != tt.ok || e != tt.entry {
+			t.Errorf("ParseRange(%q) = %v %v, want %v", tt.in, e, err, tt.entry)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package ffmpeg
+
+import (
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type FFmpeg struct {
+	stdout   io.Writer
+	stderr   io.Writer
+	input    string
+	output   string
+	format   string
+	maps     []string
+	metadata []string
+	Quiet    bool
+}
+
+func New() *FFmpeg {
+	f := new(FFmpeg)
+
+	f.stdout = os.Stdout
+	f.stderr = os.Stderr
+
+	f.Quiet = true
+
+	return f
+}
+
+func (f *FFmpeg) SetStdout(w io.Writer) {
+	f.stdout = w
+}
+
+func (f *FFmpeg) SetStderr(w io.Writer) {
+	f.stderr = w
+}
+
+func (f *FFmpeg) SetInput(input string) {
+	f.input = input
+}
+
+func (f *FFmpeg) SetOutput(output string) {
+	f.output = output
+}
+
+func (f *FFmpeg) SetFormat(format string) {
+	f.format = format
+}
+
+// SetStreams limits the output to the input streams matching the given
+// specifiers, e.g. "0:a?". All streams are copied by default.
+func (f *FFmpeg) SetStreams(specs ...string) {
+	f.maps = specs
+}
+
+// SetMetadata sets a global metadata tag of the output.
+func (f *FFmpeg) SetMetadata(key, value string) {
+	f.metadata = append(f.metadata, "-metadata", key+"="+value)
+}
+
+// Exec copies the selected streams from the input to the output without re-encoding.
+func (f *FFmpeg) Exec() error {
+	command, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-nostdin", "-y"}
+
+	if f.Quiet {
+		args = append(args, "-loglevel", "error")
+	}
+
+	args = append(args, "-i", f.input)
+	if len(f.maps) == 0 {
+		args = append(args, "-map", "0")
+	}
+	for _, m := range f.maps {
+		args = append(args, "-map", m)
+	}
+	args = append(args, "-c", "copy")
+	args = append(args, f.metadata...)
+
+	if f.format != "" {
+		args = append(args, "-f", f.format)
+	}
+
+	args = append(args, f.output)
+
+	c := exec.Command(command, args...)
+
+	c.Stderr = f.stderr
+	c.Stdout = f.stdout
+
+	log.Printf("%s %s", command, strings.Join(args, " "))
+
+	return c.Run()
+}
@@ -17,7 +17,6 @@ package hdhomerun
 
 import (
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
 	"net/url"
@@ -79,11 +78,8 @@ func (s *DeviceService) RecordedFiles(device *Device) ([]*Recording, error) {
 	}
 
 	for _, r := range recordings {
-		if r.EpisodeString != nil {
-			if _, err = fmt.Sscanf(*r.EpisodeString, "S%dE%d", &r.Season, &r.Episode); err != nil {
-				log.Printf("Error parsing EpisodeString %q: %v\n", *r.EpisodeString, err)
-				return nil, err
-			}
+		if err = r.parseEpisodeNumber(); err != nil {
+			log.Printf("Error parsing EpisodeString %q: %v\n", *r.EpisodeString, err)
 		}
 	}
 
@@ -0,0 +1,127 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package hdhomerun
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Minimum similarity of two synopses for recordings with the same title to be
+// considered the same episode.
+const synopsisSimilarity = 0.8
+
+func (r *Recording) SameEpisode(o *Recording) bool {
+	// Generic SH program ids are shared by every airing of a show.
+	if p := r.Program(); p != nil && p.Unique() && o.ProgramID != nil && *r.ProgramID == *o.ProgramID {
+		return true
+	}
+
+	// Unparseable episode numbers fall through to comparing titles.
+	if r.SeriesID != nil && o.SeriesID != nil && *r.SeriesID == *o.SeriesID &&
+		len(r.Episodes) > 0 && len(o.Episodes) > 0 {
+		return r.Season == o.Season && r.Episode == o.Episode
+	}
+
+	if r.Title == nil || o.Title == nil || r.Synopsis == nil || o.Synopsis == nil {
+		return false
+	}
+	if normalize(*r.Title) != normalize(*o.Title) {
+		return false
+	}
+	if r.EpisodeTitle != nil && o.EpisodeTitle != nil && normalize(*r.EpisodeTitle) != normalize(*o.EpisodeTitle) {
+		return false
+	}
+
+	return similarity(*r.Synopsis, *o.Synopsis) >= synopsisSimilarity
+}
+
+// FindDuplicates groups recordings of the same episode. Only groups with more
+// than one recording are returned.
+func FindDuplicates(recordings []*Recording) [][]*Recording {
+	parent := make([]int, len(recordings))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range recordings {
+		for j := i + 1; j < len(recordings); j++ {
+			if recordings[i].SameEpisode(recordings[j]) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := map[int][]*Recording{}
+	var order []int
+	for i, r := range recordings {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			order = append(order, root)
+		}
+		groups[root] = append(groups[root], r)
+	}
+
+	var duplicates [][]*Recording
+	for _, root := range order {
+		if len(groups[root]) > 1 {
+			duplicates = append(duplicates, groups[root])
+		}
+	}
+
+	return duplicates
+}
+
+func normalize(s string) string {
+	return strings.Join(words(s), " ")
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	})
+}
+
+// similarity returns the Jaccard index of the words in a and b.
+func similarity(a, b string) float64 {
+	set := map[string]int{}
+	for _, w := range words(a) {
+		set[w] |= 1
+	}
+	for _, w := range words(b) {
+		set[w] |= 2
+	}
+	if len(set) == 0 {
+		return 0
+	}
+
+	common := 0
+	for _, v := range set {
+		if v == 3 {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(set))
+}
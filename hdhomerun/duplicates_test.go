@@ -0,0 +1,91 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package hdhomerun
+
+import "testing"
+
+func str(s string) *string {
+	return &s
+}
+
+func TestSameEpisode(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Recording
+		want bool
+	}{
+		{
+			"same episode id",
+			Recording{ProgramID: str("EP012345670012")},
+			Recording{ProgramID: str("EP012345670012")},
+			true,
+		},
+		{
+			"other has no id",
+			Recording{ProgramID: str("EP012345670012")},
+			Recording{},
+			false,
+		},
+		{
+			"generic show id",
+			Recording{ProgramID: str("SH012345670000")},
+			Recording{ProgramID: str("SH012345670000")},
+			false,
+		},
+		{
+			"generic sports id",
+			Recording{ProgramID: str("SP012345670000")},
+			Recording{ProgramID: str("SP012345670000")},
+			false,
+		},
+		{
+			"same season and episode",
+			Recording{SeriesID: str("C1"), EpisodeString: str("S01E02"), Season: 1, Episode: 2, Episodes: []int{2}},
+			Recording{SeriesID: str("C1"), EpisodeString: str("S01E02"), Season: 1, Episode: 2, Episodes: []int{2}},
+			true,
+		},
+		{
+			"different episode",
+			Recording{SeriesID: str("C1"), EpisodeString: str("S01E02"), Season: 1, Episode: 2, Episodes: []int{2}},
+			Recording{SeriesID: str("C1"), EpisodeString: str("S01E03"), Season: 1, Episode: 3, Episodes: []int{3}},
+			false,
+		},
+		{
+			"unparsed episode numbers",
+			Recording{SeriesID: str("C1"), EpisodeString: str("Part One"), Title: str("Show"), Synopsis: str("One thing happens.")},
+			Recording{SeriesID: str("C1"), EpisodeString: str("Part Two"), Title: str("Show"), Synopsis: str("Something else entirely.")},
+			false,
+		},
+		{
+			"similar synopsis",
+			Recording{Title: str("Show"), Synopsis: str("Jim and Pam plan a party for the office.")},
+			Recording{Title: str("Show!"), Synopsis: str("Jim and Pam plan a party for the office")},
+			true,
+		},
+		{
+			"different episode titles",
+			Recording{Title: str("Show"), EpisodeTitle: str("One"), Synopsis: str("A recap.")},
+			Recording{Title: str("Show"), EpisodeTitle: str("Two"), Synopsis: str("A recap.")},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.SameEpisode(&tt.b); got != tt.want {
+			t.Errorf("%s: SameEpisode = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package hdhomerun
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	seasonEpisodeRe = regexp.MustCompile(`^(?:S(\d+))?((?:[ ._-]*E\d+(?:-E?\d+)?)+)$`)
+	crossEpisodeRe  = regexp.MustCompile(`^(\d+)X(\d+)(?:-(\d+))?$`)
+	absoluteRe      = regexp.MustCompile(`^(?:EP|#)?\s*(\d+)$`)
+	episodeRe       = regexp.MustCompile(`E(\d+)(?:-E?(\d+))?`)
+)
+
+// EpisodeNumber is a parsed HDHomeRun EpisodeNumber string. Season is zero
+// when the episode number is absolute or the season is unknown.
+type EpisodeNumber struct {
+	Raw      string
+	Season   int
+	Episodes []int
+}
+
+func ParseEpisodeNumber(s string) (*EpisodeNumber, error) {
+	e := &EpisodeNumber{Raw: s}
+	upper := strings.ToUpper(strings.TrimSpace(s))
+
+	if m := seasonEpisodeRe.FindStringSubmatch(upper); m != nil {
+		if m[1] != "" {
+			e.Season, _ = strconv.Atoi(m[1])
+		}
+		for _, em := range episodeRe.FindAllStringSubmatch(m[2], -1) {
+			e.addEpisodes(em[1], em[2])
+		}
+	} else if m := crossEpisodeRe.FindStringSubmatch(upper); m != nil {
+		e.Season, _ = strconv.Atoi(m[1])
+		e.addEpisodes(m[2], m[3])
+	} else if m := absoluteRe.FindStringSubmatch(upper); m != nil {
+		e.addEpisodes(m[1], "")
+	} else {
+		return nil, fmt.Errorf("Unrecognized episode number %q", s)
+	}
+
+	return e, nil
+}
+
+// addEpisodes appends first, or the range first-last, to the episode list.
+func (e *EpisodeNumber) addEpisodes(first, last string) {
+	start, _ := strconv.Atoi(first)
+	end := start
+	if last != "" {
+		end, _ = strconv.Atoi(last)
+	}
+	if end < start {
+		end = start
+	}
+
+	for i := start; i <= end; i++ {
+		e.Episodes = append(e.Episodes, i)
+	}
+}
+
+func (e *EpisodeNumber) Episode() int {
+	if len(e.Episodes) == 0 {
+		return 0
+	}
+	return e.Episodes[0]
+}
+
+func (e *EpisodeNumber) String() string {
+	var s string
+	if e.Season > 0 {
+		s = fmt.Sprintf("S%02d", e.Season)
+	}
+
+	n := len(e.Episodes)
+	if n > 2 && e.Episodes[n-1]-e.Episodes[0] == n-1 {
+		return s + fmt.Sprintf("E%02d-E%02d", e.Episodes[0], e.Episodes[n-1])
+	}
+	for _, ep := range e.Episodes {
+		s += fmt.Sprintf("E%02d", ep)
+	}
+
+	return s
+}
@@ -0,0 +1,66 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package hdhomerun
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEpisodeNumber(t *testing.T) {
+	tests := []struct {
+		in       string
+		season   int
+		episodes []int
+		str      string
+	}{
+		{"S01E05", 1, []int{5}, "S01E05"},
+		{"s1 e5", 1, []int{5}, "S01E05"},
+		{"S02E01E02", 2, []int{1, 2}, "S02E01E02"},
+		{"S02E01-E02", 2, []int{1, 2}, "S02E01E02"},
+		{"S10E03-05", 10, []int{3, 4, 5}, "S10E03-E05"},
+		{"S03E10-E08", 3, []int{10}, "S03E10"},
+		{"E12", 0, []int{12}, "E12"},
+		{"2x05", 2, []int{5}, "S02E05"},
+		{"1X01-02", 1, []int{1, 2}, "S01E01E02"},
+		{"EP123", 0, []int{123}, "E123"},
+		{"#7", 0, []int{7}, "E07"},
+		{" 42 ", 0, []int{42}, "E42"},
+		{"S01", 0, nil, ""},
+		{"Pilot", 0, nil, ""},
+		{"", 0, nil, ""},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseEpisodeNumber(tt.in)
+		if tt.episodes == nil {
+			if err == nil {
+				t.Errorf("ParseEpisodeNumber(%q) = %v, want error", tt.in, e)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseEpisodeNumber(%q): %v", tt.in, err)
+			continue
+		}
+		if e.Season != tt.season || !reflect.DeepEqual(e.Episodes, tt.episodes) || e.String() != tt.str {
+			t.Errorf("ParseEpisodeNumber(%q) = S%d %v %q, want S%d %v %q", tt.in, e.Season, e.Episodes, e.String(), tt.season, tt.episodes, tt.str)
+		}
+		if e.Episode() != tt.episodes[0] {
+			t.Errorf("ParseEpisodeNumber(%q).Episode() = %d", tt.in, e.Episode())
+		}
+	}
+}
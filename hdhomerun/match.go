@@ -0,0 +1,176 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package hdhomerun
+
+import (
+	"log"
+	"path"
+	"path/filepath"
+)
+
+// Maximum difference between the record start time reported by the DVR and
+// the one embedded in the file.
+const startTimeTolerance = 5
+
+type localFile struct {
+	path string
+	size int64
+	meta *RecordingFile
+}
+
+type ScanResult struct {
+	Matched             []*Recording
+	UnmatchedRecordings []*Recording
+	UnmatchedFiles      []string
+	// Unlisted has a recording for each unmatched file with a title in its
+	// metadata header or broadcast guide data. They have no CmdURL.
+	Unlisted []*Recording
+}
+
+type matcher struct {
+	recordings []*Recording
+	files      []*localFile
+	matched    map[*Recording]*localFile
+	used       map[*localFile]bool
+}
+
+func (m *matcher) match(r *Recording, f *localFile) {
+	m.matched[r] = f
+	m.used[f] = true
+	r.LocalFilename = &f.path
+}
+
+// each calls fn for every pair of unmatched recording and file, stopping at
+// the first match for a file.
+func (m *matcher) each(fn func(r *Recording, f *localFile) bool) {
+	for _, f := range m.files {
+		if m.used[f] {
+			continue
+		}
+		for _, r := range m.recordings {
+			if _, ok := m.matched[r]; ok {
+				continue
+			}
+			if fn(r, f) {
+				m.match(r, f)
+				break
+			}
+		}
+	}
+}
+
+func sameProgram(r *Recording, f *localFile) bool {
+	return f.meta != nil && f.meta.ProgramID != nil && r.ProgramID != nil &&
+		*f.meta.ProgramID == *r.ProgramID
+}
+
+// matchFiles pairs recordings with files on disk. The DVR filename is the
+// most reliable, followed by ProgramID and record start time, then title and
+// start time for files without a ProgramID. Files with only a matching
+// ProgramID are paired when the choice is unambiguous or the file size
+// agrees.
+func matchFiles(files []*localFile, recordings []*Recording) *ScanResult {
+	m := &matcher{
+		recordings: recordings,
+		matched:    map[*Recording]*localFile{},
+		used:       map[*localFile]bool{},
+	}
+	for _, f := range files {
+		if f.size > 0 {
+			m.files = append(m.files, f)
+		}
+	}
+
+	m.each(func(r *Recording, f *localFile) bool {
+		return r.Filename != nil && path.Base(filepath.ToSlash(*r.Filename)) == filepath.Base(f.path)
+	})
+
+	m.each(func(r *Recording, f *localFile) bool {
+		if !sameProgram(r, f) || r.RecordStartTime == nil || f.meta.RecordStartTime == nil {
+			return false
+		}
+		d := *r.RecordStartTime - *f.meta.RecordStartTime
+		return d >= -startTimeTolerance && d <= startTimeTolerance
+	})
+
+	// Files recovered from broadcast guide data have no ProgramID.
+	m.each(func(r *Recording, f *localFile) bool {
+		if f.meta == nil || f.meta.ProgramID != nil || f.meta.Title == nil || r.Title == nil ||
+			f.meta.StartTime == nil || r.StartTime == nil {
+			return false
+		}
+		return *f.meta.Title == *r.Title && *f.meta.StartTime == *r.StartTime
+	})
+
+	m.each(func(r *Recording, f *localFile) bool {
+		if !sameProgram(r, f) {
+			return false
+		}
+		if r.FileSize != nil {
+			return *r.FileSize == f.size
+		}
+		return m.candidates(f) == 1
+	})
+
+	result := &ScanResult{}
+	for _, r := range recordings {
+		if _, ok := m.matched[r]; ok {
+			result.Matched = append(result.Matched, r)
+		} else {
+			result.UnmatchedRecordings = append(result.UnmatchedRecordings, r)
+		}
+	}
+	for _, f := range files {
+		if m.used[f] {
+			continue
+		}
+		result.UnmatchedFiles = append(result.UnmatchedFiles, f.path)
+		if r := f.recording(); r != nil {
+			result.Unlisted = append(result.Unlisted, r)
+		}
+	}
+
+	return result
+}
+
+// recording returns a recording made from the metadata of f, or nil if it
+// has no title.
+func (f *localFile) recording() *Recording {
+	if f.meta == nil || f.meta.Title == nil || f.size == 0 {
+		return nil
+	}
+
+	r := Recording(*f.meta)
+	r.LocalFilename = &f.path
+	r.CmdURL = nil
+	if err := r.parseEpisodeNumber(); err != nil {
+		log.Printf("Error parsing EpisodeString %q: %v\n", *r.EpisodeString, err)
+	}
+
+	return &r
+}
+
+// candidates returns the number of unmatched recordings sharing the
+// ProgramID of f.
+func (m *matcher) candidates(f *localFile) int {
+	n := 0
+	for _, r := range m.recordings {
+		if _, ok := m.matched[r]; !ok && sameProgram(r, f) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,78 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package hdhomerun
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchFiles(t *testing.T) {
+	start := int64(1000)
+	other := int64(5000)
+
+	recordings := []*Recording{
+		{Filename: str("Show/Show S01E01 20180102 [20180102-2000].mpg"), Title: str("Show")},
+		{ProgramID: str("EP012345670002"), RecordStartTime: &start, Title: str("Show")},
+		{Title: str("News"), StartTime: &start},
+		{Filename: str("Missing.mpg"), Title: str("Missing")},
+	}
+	files := []*localFile{
+		{path: "/dvr/Show/Show S01E01 20180102 [20180102-2000].mpg", size: 10},
+		{path: "/dvr/Show/renamed.mpg", size: 10, meta: &RecordingFile{ProgramID: str("EP012345670002"), RecordStartTime: &start}},
+		{path: "/dvr/News/news.mpg", size: 10, meta: &RecordingFile{Title: str("News"), StartTime: &start}},
+		{path: "/dvr/Old/old.mpg", size: 10, meta: &RecordingFile{Title: str("Old"), EpisodeString: str("S02E03"), StartTime: &other}},
+		{path: "/dvr/Broken/broken.mpg", size: 10},
+		{path: "/dvr/Empty/empty.mpg", size: 0, meta: &RecordingFile{Title: str("Empty")}},
+	}
+
+	result := matchFiles(files, recordings)
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"matched", localNames(result.Matched), []string{
+			"/dvr/Show/Show S01E01 20180102 [20180102-2000].mpg", "/dvr/Show/renamed.mpg", "/dvr/News/news.mpg",
+		}},
+		{"unmatched files", result.UnmatchedFiles, []string{
+			"/dvr/Old/old.mpg", "/dvr/Broken/broken.mpg", "/dvr/Empty/empty.mpg",
+		}},
+		{"unlisted", localNames(result.Unlisted), []string{"/dvr/Old/old.mpg"}},
+	}
+
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if len(result.UnmatchedRecordings) != 1 || *result.UnmatchedRecordings[0].Title != "Missing" {
+		t.Errorf("unmatched recordings = %v", result.UnmatchedRecordings)
+	}
+	if r := result.Unlisted[0]; r.Season != 2 || r.Episode != 3 || r.CmdURL != nil {
+		t.Errorf("unlisted recording = S%dE%d %v", r.Season, r.Episode, r.CmdURL)
+	}
+}
+
+func localNames(recordings []*Recording) []string {
+	var names []string
+	for _, r := range recordings {
+		names = append(names, *r.LocalFilename)
+	}
+	return names
+}
@@ -0,0 +1,122 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package hdhomerun
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+type ProgramType int
+
+const (
+	UnknownProgram ProgramType = iota
+	EpisodeProgram
+	ShowProgram
+	MovieProgram
+	SportsProgram
+)
+
+var programTypes = map[string]ProgramType{
+	"EP": EpisodeProgram,
+	"SH": ShowProgram,
+	"MV": MovieProgram,
+	"SP": SportsProgram,
+}
+
+func (t ProgramType) String() string {
+	switch t {
+	case EpisodeProgram:
+		return "episode"
+	case ShowProgram:
+		return "show"
+	case MovieProgram:
+		return "movie"
+	case SportsProgram:
+		return "sports"
+	}
+	return "unknown"
+}
+
+var programIDRe = regexp.MustCompile(`^([A-Z]{2})(\d{8})(\d{4})$`)
+
+// ProgramID is a Gracenote (TMS) program identifier, e.g. EP012345670012.
+// The series root is shared by all programs of a series, the episode index
+// is zero for movies and shows without episode information.
+type ProgramID struct {
+	Raw          string
+	Type         ProgramType
+	SeriesRoot   string
+	EpisodeIndex int
+}
+
+func ParseProgramID(s string) (*ProgramID, error) {
+	m := programIDRe.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("Invalid ProgramID %q", s)
+	}
+
+	p := &ProgramID{
+		Raw:        s,
+		Type:       programTypes[m[1]],
+		SeriesRoot: m[2],
+	}
+	p.EpisodeIndex, _ = strconv.Atoi(m[3])
+
+	return p, nil
+}
+
+// Unique reports whether the ProgramID identifies a single program rather
+// than a generic entry shared by several airings of a show. Sports programs
+// without an episode index are shared by every game of a series.
+func (p *ProgramID) Unique() bool {
+	switch p.Type {
+	case ShowProgram, UnknownProgram:
+		return false
+	case SportsProgram:
+		return p.EpisodeIndex != 0
+	}
+	return true
+}
+
+func (p *ProgramID) String() string {
+	return p.Raw
+}
+
+// Program returns the parsed ProgramID of the recording, or nil when it is
+// missing or malformed.
+func (r *Recording) Program() *ProgramID {
+	if r.ProgramID == nil {
+		return nil
+	}
+
+	p, err := ParseProgramID(*r.ProgramID)
+	if err != nil {
+		return nil
+	}
+
+	return p
+}
+
+// IsMovie reports whether r is a movie by its ProgramID, or its category for
+// recordings without a movie ProgramID.
+func (r *Recording) IsMovie() bool {
+	if p := r.Program(); p != nil && p.Type == MovieProgram {
+		return true
+	}
+	return r.Category != nil && *r.Category == "movie"
+}
@@ -0,0 +1,80 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package hdhomerun
+
+import "testing"
+
+func TestParseProgramID(t *testing.T) {
+	tests := []struct {
+		id      string
+		valid   bool
+		typ     ProgramType
+		root    string
+		episode int
+		unique  bool
+	}{
+		{"EP012345670012", true, EpisodeProgram, "01234567", 12, true},
+		{"SH012345670000", true, ShowProgram, "01234567", 0, false},
+		{"MV000123450000", true, MovieProgram, "00012345", 0, true},
+		{"SP003456780123", true, SportsProgram, "00345678", 123, true},
+		{"SP003456780000", true, SportsProgram, "00345678", 0, false},
+		{"XX012345670012", true, UnknownProgram, "01234567", 12, false},
+		{"EP01234567001", false, 0, "", 0, false},
+		{"ep012345670012", false, 0, "", 0, false},
+		{"", false, 0, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		p, err := ParseProgramID(tt.id)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("ParseProgramID(%q) succeeded, want error", tt.id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseProgramID(%q): %v", tt.id, err)
+			continue
+		}
+		if p.Type != tt.typ || p.SeriesRoot != tt.root || p.EpisodeIndex != tt.episode {
+			t.Errorf("ParseProgramID(%q) = %v %s %d, want %v %s %d", tt.id, p.Type, p.SeriesRoot, p.EpisodeIndex, tt.typ, tt.root, tt.episode)
+		}
+		if p.Unique() != tt.unique {
+			t.Errorf("ParseProgramID(%q).Unique() = %v, want %v", tt.id, p.Unique(), tt.unique)
+		}
+	}
+}
+
+func TestIsMovie(t *testing.T) {
+	movie, series := "movie", "series"
+
+	tests := []struct {
+		r    Recording
+		want bool
+	}{
+		{Recording{ProgramID: str("MV000123450000")}, true},
+		{Recording{ProgramID: str("EP012345670012")}, false},
+		{Recording{Category: &movie}, true},
+		{Recording{Category: &series, ProgramID: str("SH012345670000")}, false},
+		{Recording{}, false},
+	}
+
+	for i, tt := range tests {
+		if got := tt.r.IsMovie(); got != tt.want {
+			t.Errorf("%d: IsMovie = %v, want %v", i, got, tt.want)
+		}
+	}
+}
@@ -16,16 +16,15 @@
 package hdhomerun
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/json"
 	"log"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/ziutek/dvb/ts"
+	"github.com/saintdev/hdhrdvrutil/mpegts"
 )
 
 type RecordingService struct {
@@ -33,49 +32,81 @@ type RecordingService struct {
 }
 
 type Recording struct {
-	Category      *string
-	CmdURL        *string
-	EpisodeTitle  *string
-	EpisodeString *string `json:"EpisodeNumber"`
-	ImageURL      *string
-	ProgramID     *string
-	SeriesID      *string
-	Synopsis      *string
-	Title         *string
-	Filename      *string
-	Season        int
-	Episode       int
+	Category        *string
+	ChannelName     *string
+	ChannelNumber   *string
+	CmdURL          *string
+	EpisodeTitle    *string
+	EpisodeString   *string `json:"EpisodeNumber"`
+	ImageURL        *string
+	ProgramID       *string
+	SeriesID        *string
+	Synopsis        *string
+	Title           *string
+	Filename        *string
+	FileSize        *int64
+	LocalFilename   *string `json:"-"`
+	StartTime       *int64
+	EndTime         *int64
+	RecordStartTime *int64
+	RecordEndTime   *int64
+	RecordSuccess   *int
+	Season          int
+	Episode         int
+	Episodes        []int `json:"-"`
 }
 
-type RecordingFile Recording
+func (r *Recording) parseEpisodeNumber() error {
+	if r.EpisodeString == nil {
+		return nil
+	}
 
-func (r *RecordingFile) Parse() error {
-	file, err := os.Open(*r.Filename)
+	e, err := ParseEpisodeNumber(*r.EpisodeString)
 	if err != nil {
-		log.Printf("Error: Unable to open file %q: %v\n", r.Filename, err)
 		return err
 	}
-	defer file.Close()
+	r.Season = e.Season
+	r.Episode = e.Episode()
+	r.Episodes = e.Episodes
 
-	tsfile := ts.NewPktStreamReader(bufio.NewReader(file))
+	return nil
+}
 
-	var buf [ts.PktLen]byte
-	var jsonBuf []byte
+func (r *Recording) ScheduledDuration() time.Duration {
+	if r.StartTime == nil || r.EndTime == nil {
+		return 0
+	}
+	return time.Duration(*r.EndTime-*r.StartTime) * time.Second
+}
 
-	for i := 0; i < 64; i++ {
-		pkt := ts.AsPkt(buf[:])
-		if err := tsfile.ReadPkt(pkt); err != nil {
-			log.Printf("Error: Unable to read TS packet: %v\n", err)
-			continue
-		}
-		if pkt.Pid() != 0x1FFA {
-			break
-		}
-		payload := pkt.Payload()
-		jsonBuf = append(jsonBuf, payload...)
+// ScheduledOffsets returns the offsets of the scheduled start and end time
+// from the start of the recording, which includes any padding.
+func (r *Recording) ScheduledOffsets() (start, end time.Duration, ok bool) {
+	if r.StartTime == nil || r.EndTime == nil || r.RecordStartTime == nil {
+		return 0, 0, false
 	}
 
-	jsonBuf = bytes.Trim(jsonBuf, "\xFF")
+	start = time.Duration(*r.StartTime-*r.RecordStartTime) * time.Second
+	end = time.Duration(*r.EndTime-*r.RecordStartTime) * time.Second
+
+	return start, end, true
+}
+
+func (r *Recording) Succeeded() bool {
+	// Older record engines don't report RecordSuccess, assume the best.
+	return r.RecordSuccess == nil || *r.RecordSuccess != 0
+}
+
+type RecordingFile Recording
+
+func (r *RecordingFile) Parse() error {
+	jsonBuf, err := mpegts.ReadMetadataFile(*r.Filename)
+	if err == mpegts.ErrNoMetadata {
+		return r.parseGuide()
+	} else if err != nil {
+		log.Printf("Error: Unable to read metadata from %q: %v\n", *r.Filename, err)
+		return err
+	}
 
 	if err = json.Unmarshal(jsonBuf, &r); err != nil {
 		log.Printf("Error parsing TS packet JSON: %v\n", err)
@@ -85,21 +116,46 @@ func (r *RecordingFile) Parse() error {
 	return nil
 }
 
+// parseGuide fills in the metadata from the guide data broadcast in the
+// stream, for recordings without the HDHomeRun header.
+func (r *RecordingFile) parseGuide() error {
+	guide, err := mpegts.ReadGuideInfoFile(*r.Filename)
+	if err != nil {
+		log.Printf("Error: Unable to read guide data from %q: %v\n", *r.Filename, err)
+		return err
+	}
+
+	e := guide.Event
+	start, end, recordStart := e.Start.Unix(), e.End().Unix(), guide.Time.Unix()
+
+	r.Title = &e.Title
+	if e.Description != "" {
+		r.Synopsis = &e.Description
+	}
+	if guide.ChannelName != "" {
+		r.ChannelName = &guide.ChannelName
+	}
+	if guide.ChannelNumber != "" {
+		r.ChannelNumber = &guide.ChannelNumber
+	}
+	r.StartTime = &start
+	r.EndTime = &end
+	r.RecordStartTime = &recordStart
+
+	return nil
+}
+
 //FIXME: This needs a better name
-func (s *RecordingService) ScanRecordingsDir(dir string, recordings []*Recording) error {
-	episodeMap := map[string]*Recording{}
+func (s *RecordingService) ScanRecordingsDir(dir string, recordings []*Recording) (*ScanResult, error) {
+	var files []*localFile
 
 	dir, err := filepath.Abs(dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return err
-	}
-
-	for i := range recordings {
-		episodeMap[*recordings[i].ProgramID] = recordings[i]
+		return nil, err
 	}
 
 	err = filepath.Walk(dir, func(path string, finfo os.FileInfo, err error) error {
@@ -113,22 +169,24 @@ func (s *RecordingService) ScanRecordingsDir(dir string, recordings []*Recording
 		}
 
 		if !finfo.IsDir() && filepath.Ext(finfo.Name()) == ".mpg" {
-			file := &RecordingFile{Filename: &path}
-			if err := file.Parse(); err != nil {
-				return err
-			}
+			f := &localFile{path: path, size: finfo.Size()}
 
-			r, ok := episodeMap[*file.ProgramID]
-			if !ok {
-				return nil
+			meta := &RecordingFile{Filename: &path}
+			if err := meta.Parse(); err != nil {
+				log.Printf("No metadata in %q: %v\n", path, err)
+			} else {
+				f.meta = meta
 			}
-			r.Filename = &path
+			files = append(files, f)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
+	return matchFiles(files, recordings), nil
 }
 
 func (s *RecordingService) Delete(recording *Recording, rerecord bool) error {
@@ -0,0 +1,44 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mkvmerge
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+type ChapterMark struct {
+	Start time.Duration
+	Name  string
+}
+
+// SetChapters replaces the chapters of the output.
+func (m *MkvMerge) SetChapters(chapters []ChapterMark) {
+	m.chapters = chapters
+}
+
+// encodeChapters writes the chapters in the simple OGM format understood by
+// mkvmerge.
+func encodeChapters(w io.Writer, chapters []ChapterMark) error {
+	for i, c := range chapters {
+		if _, err := fmt.Fprintf(w, "CHAPTER%02d=%s\nCHAPTER%02dNAME=%s\n", i+1, formatTimestamp(c.Start), i+1, c.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -23,19 +23,25 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 )
 
 type MkvMerge struct {
-	stdout   io.Writer
-	stderr   io.Writer
-	stdin    io.Reader
-	input    string
-	output   string
-	tags     *Tags
-	tempFile *os.File
-	webm     bool
-	Quiet    bool
-	Verbose  bool
+	stdout    io.Writer
+	stderr    io.Writer
+	stdin     io.Reader
+	input     string
+	output    string
+	tags      *Tags
+	tracks    map[int]*trackOptions
+	parts     []Part
+	chapters  []ChapterMark
+	subtitles []subtitleInput
+	tempFile  *os.File
+	chapFile  *os.File
+	webm      bool
+	Quiet     bool
+	Verbose   bool
 }
 
 func New() *MkvMerge {
@@ -110,7 +116,28 @@ func (m *MkvMerge) Exec() error {
 		args = append(args, "--global-tags", m.tempFile.Name())
 	}
 
+	if len(m.chapters) > 0 {
+		m.chapFile, err = ioutil.TempFile("", filepath.Base(os.Args[0]))
+		if err != nil {
+			return err
+		}
+
+		encodeChapters(m.chapFile, m.chapters)
+
+		if err = m.chapFile.Close(); err != nil {
+			return err
+		}
+
+		args = append(args, "--chapters", m.chapFile.Name())
+	}
+
+	if len(m.parts) > 0 {
+		args = append(args, "--split", m.splitArg())
+	}
+
+	args = append(args, m.trackArgs()...)
 	args = append(args, m.input)
+	args = append(args, m.subtitleArgs()...)
 
 	c := exec.Command(command, args...)
 
@@ -120,10 +147,35 @@ func (m *MkvMerge) Exec() error {
 
 	log.Printf("%s %s", command, strings.Join(args, " "))
 
-	return c.Run()
+	err = c.Run()
+	if len(m.parts) > 0 {
+		if Succeeded(err) {
+			m.renameSplitOutput()
+		} else {
+			os.Remove(m.splitOutput())
+		}
+	}
+
+	return err
+}
+
+// Succeeded reports whether mkvmerge wrote its output given the error from
+// running it, it exits with 1 on warnings.
+func Succeeded(err error) bool {
+	if exitError, ok := err.(*exec.ExitError); ok {
+		return exitError.Sys().(syscall.WaitStatus).ExitStatus() == 1
+	}
+	return err == nil
 }
 
 func (m *MkvMerge) Close() error {
+	if m.chapFile != nil {
+		os.Remove(m.chapFile.Name())
+		m.chapFile = nil
+	}
+	if m.tempFile == nil {
+		return nil
+	}
 	fileName := m.tempFile.Name()
 	m.tempFile = nil
 	return os.Remove(fileName)
@@ -163,3 +215,41 @@ func (m *MkvMerge) SetSynopsisTag(synopsis string) {
 	}
 	m.tags.setSynopsis(synopsis)
 }
+
+// SetTag adds a simple tag with the given name to the global tags.
+func (m *MkvMerge) SetTag(name, value string) {
+	if m.tags == nil {
+		m.tags = newTags()
+	}
+	m.tags.setTag(name, value)
+}
+
+// ExtractTags reads the global tags of a Matroska file using mkvextract.
+func ExtractTags(filename string) (*Tags, error) {
+	command, err := exec.LookPath("mkvextract")
+	if err != nil {
+		return nil, err
+	}
+
+	tempFile, err := ioutil.TempFile("", filepath.Base(os.Args[0]))
+	if err != nil {
+		return nil, err
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	c := exec.Command(command, filename, "tags", tempFile.Name())
+	c.Stderr = os.Stderr
+
+	if err = c.Run(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(tempFile.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return decodeTags(f)
+}
@@ -0,0 +1,97 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mkvmerge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitArg(t *testing.T) {
+	tests := []struct {
+		parts []Part
+		want  string
+	}{
+		{[]Part{{Start: 90 * time.Second}}, "parts:00:01:30.000-"},
+		{[]Part{{End: time.Hour + 1500*time.Microsecond}}, "parts:-01:00:00.002"},
+		{[]Part{{End: 10 * time.Minute}, {Start: 12 * time.Minute, End: 30 * time.Minute}, {Start: 31*time.Minute + 250*time.Millisecond}},
+			"parts:-00:10:00.000,+00:12:00.000-00:30:00.000,+00:31:00.250-"},
+	}
+
+	for _, tt := range tests {
+		m := New()
+		m.SetSplitParts(tt.parts)
+		if got := m.splitArg(); got != tt.want {
+			t.Errorf("splitArg(%v) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
+
+// fakeMkvMerge puts an mkvmerge on the PATH that writes the numbered split
+// output and exits with the given status.
+func fakeMkvMerge(t *testing.T, dir string, status int) {
+	script := fmt.Sprintf("#!/bin/sh\necho part > \"${2%%.mkv}-001.mkv\"\nexit %d\n", status)
+	if err := ioutil.WriteFile(filepath.Join(dir, "mkvmerge"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecSplit(t *testing.T) {
+	tests := []struct {
+		status  int
+		renamed bool
+	}{
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+
+	dir, err := ioutil.TempDir("", "mkvmerge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	for _, tt := range tests {
+		fakeMkvMerge(t, dir, tt.status)
+		output := filepath.Join(dir, fmt.Sprintf("out%d.mkv", tt.status))
+
+		m := New()
+		m.SetInput(filepath.Join(dir, "in.ts"))
+		m.SetOutput(output)
+		m.SetSplitParts([]Part{{Start: time.Minute}})
+		err := m.Exec()
+		m.Close()
+		if Succeeded(err) != tt.renamed {
+			t.Errorf("exit %d: Exec() = %v", tt.status, err)
+		}
+
+		if _, err := os.Stat(output); (err == nil) != tt.renamed {
+			t.Errorf("exit %d: output exists = %v, want %v", tt.status, err == nil, tt.renamed)
+		}
+		if _, err := os.Stat(m.splitOutput()); err == nil {
+			t.Errorf("exit %d: numbered output left behind", tt.status)
+		}
+	}
+}
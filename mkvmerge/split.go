@@ -0,0 +1,86 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mkvmerge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Part is a range of the input to keep. An End of zero means the end of the
+// input.
+type Part struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// SetSplitParts limits the output to the given parts of the input, joined
+// into a single file.
+func (m *MkvMerge) SetSplitParts(parts []Part) {
+	m.parts = parts
+}
+
+func formatTimestamp(d time.Duration) string {
+	d = d.Round(time.Millisecond)
+	h := d / time.Hour
+	d -= h * time.Hour
+	min := d / time.Minute
+	d -= min * time.Minute
+	sec := d / time.Second
+	d -= sec * time.Second
+
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, min, sec, d/time.Millisecond)
+}
+
+func (m *MkvMerge) splitArg() string {
+	var parts []string
+
+	for i, p := range m.parts {
+		var s string
+		if i > 0 {
+			s = "+"
+		}
+		if p.Start > 0 {
+			s += formatTimestamp(p.Start)
+		}
+		s += "-"
+		if p.End > 0 {
+			s += formatTimestamp(p.End)
+		}
+		parts = append(parts, s)
+	}
+
+	return "parts:" + strings.Join(parts, ",")
+}
+
+// splitOutput is the numbered file mkvmerge writes when splitting.
+func (m *MkvMerge) splitOutput() string {
+	ext := filepath.Ext(m.output)
+	return strings.TrimSuffix(m.output, ext) + "-001" + ext
+}
+
+// renameSplitOutput moves the numbered file mkvmerge creates when splitting
+// to the requested output name, all parts are joined into one file.
+func (m *MkvMerge) renameSplitOutput() {
+	numbered := m.splitOutput()
+
+	if _, err := os.Stat(numbered); err == nil {
+		os.Rename(numbered, m.output)
+	}
+}
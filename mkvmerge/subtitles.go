@@ -0,0 +1,43 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mkvmerge
+
+type subtitleInput struct {
+	filename string
+	language string
+	name     string
+}
+
+// AddSubtitles adds a subtitle file to be muxed after the main input.
+func (m *MkvMerge) AddSubtitles(filename, language, name string) {
+	m.subtitles = append(m.subtitles, subtitleInput{filename, language, name})
+}
+
+func (m *MkvMerge) subtitleArgs() []string {
+	var args []string
+
+	for _, s := range m.subtitles {
+		if s.language != "" {
+			args = append(args, "--language", "0:"+s.language)
+		}
+		if s.name != "" {
+			args = append(args, "--track-name", "0:"+s.name)
+		}
+		args = append(args, "--default-track", "0:no", s.filename)
+	}
+
+	return args
+}
@@ -102,6 +102,12 @@ func (t *Tags) setEpisode(episode int) {
 	tag.SimpleTags = append(tag.SimpleTags, SimpleTag{Name: "PART_NUMBER", String: fmt.Sprint(episode)})
 }
 
+func (t *Tags) setTag(name, value string) {
+	i := t.tagMap[Episode]
+	tag := &t.Tags[i]
+	tag.SimpleTags = append(tag.SimpleTags, SimpleTag{Name: name, String: value})
+}
+
 func (t *Tags) setSeason(season int) {
 	i, ok := t.tagMap[Season]
 	if !ok {
@@ -116,3 +122,38 @@ func (t *Tags) setSeason(season int) {
 
 	tag.SimpleTags = append(tag.SimpleTags, SimpleTag{Name: "PART_NUMBER", String: fmt.Sprint(season)})
 }
+
+func decodeTags(r io.Reader) (*Tags, error) {
+	t := &Tags{}
+	if err := xml.NewDecoder(r).Decode(t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Get returns the value of the simple tag name at the target level. Tags of
+// single tracks or chapters are skipped.
+func (t *Tags) Get(target TargetTypeValue, name string) (string, bool) {
+	for _, tag := range t.Tags {
+		if tag.Target != nil && (len(tag.Target.TrackUIDs) > 0 || len(tag.Target.ChapterUIDs) > 0) {
+			continue
+		}
+
+		level := Episode
+		if tag.Target != nil && tag.Target.TargetTypeValue != 0 {
+			level = tag.Target.TargetTypeValue
+		}
+		if level != target {
+			continue
+		}
+
+		for _, s := range tag.SimpleTags {
+			if s.Name == name {
+				return s.String, true
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,146 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mkvmerge
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+type Track struct {
+	ID         int
+	Type       string
+	Codec      string
+	Properties struct {
+		Number   int
+		Language string
+	}
+}
+
+// Pid returns the MPEG-TS PID of a track identified in a transport stream.
+func (t *Track) Pid() int {
+	return t.Properties.Number
+}
+
+type identification struct {
+	Container struct {
+		Properties struct {
+			Duration int64
+		}
+	}
+	Tracks []Track
+}
+
+func identify(filename string) (*identification, error) {
+	command, err := exec.LookPath("mkvmerge")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command(command, "--identification-format", "json", "--identify", filename).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &identification{}
+	if err = json.Unmarshal(out, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Identify lists the tracks mkvmerge finds in filename.
+func Identify(filename string) ([]Track, error) {
+	info, err := identify(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return info.Tracks, nil
+}
+
+// Duration returns the duration of a file as reported by mkvmerge.
+func Duration(filename string) (time.Duration, error) {
+	info, err := identify(filename)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(info.Container.Properties.Duration), nil
+}
+
+type trackOptions struct {
+	language string
+	name     string
+	def      *bool
+}
+
+func (m *MkvMerge) track(id int) *trackOptions {
+	if m.tracks == nil {
+		m.tracks = map[int]*trackOptions{}
+	}
+	t, ok := m.tracks[id]
+	if !ok {
+		t = &trackOptions{}
+		m.tracks[id] = t
+	}
+	return t
+}
+
+func (m *MkvMerge) SetTrackLanguage(id int, language string) {
+	m.track(id).language = language
+}
+
+func (m *MkvMerge) SetTrackName(id int, name string) {
+	m.track(id).name = name
+}
+
+func (m *MkvMerge) SetDefaultTrack(id int, def bool) {
+	m.track(id).def = &def
+}
+
+func (m *MkvMerge) trackArgs() []string {
+	var args []string
+
+	ids := make([]int, 0, len(m.tracks))
+	for id := range m.tracks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		t := m.tracks[id]
+		if t.language != "" {
+			args = append(args, "--language", fmt.Sprintf("%d:%s", id, t.language))
+		}
+		if t.name != "" {
+			args = append(args, "--track-name", fmt.Sprintf("%d:%s", id, t.name))
+		}
+		if t.def != nil {
+			flag := "no"
+			if *t.def {
+				flag = "yes"
+			}
+			args = append(args, "--default-track", fmt.Sprintf("%d:%s", id, flag))
+		}
+	}
+
+	return args
+}
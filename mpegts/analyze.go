@@ -0,0 +1,381 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+// Largest PCR interval allowed by ISO/IEC 13818-1, and the jump we treat as a
+// discontinuity rather than a late PCR.
+const (
+	maxPCRInterval = 100 * time.Millisecond
+	maxPCRJump     = 1 * time.Second
+)
+
+// Largest step back between presentation timestamps of a PID that isn't a
+// new timebase, well above any frame reordering.
+const maxPTSReorder = 5 * ptsClock
+
+type PidStats struct {
+	Pid                int16
+	Packets            int64
+	TransportErrors    int64
+	ContinuityErrors   int64
+	PCRs               int64
+	PCRDiscontinuities int64
+	PCRIntervalErrors  int64
+	MaxPCRInterval     time.Duration
+	MaxPCRJitter       time.Duration
+	Duration           time.Duration
+	FirstPTS           int64
+	LastPTS            int64
+	AvgBitrate         float64
+	PeakBitrate        float64
+
+	lastCC     int
+	haveCC     bool
+	lastPCR    int64
+	lastPCRPos int64
+	havePCR    bool
+	pcrReset   bool
+	pcrRate    float64
+	elapsed    int64
+	havePTS    bool
+	lastPTS    int64
+	ptsReset   bool
+	spans      int
+	spanStart  int64
+	spanEnd    int64
+	spanTotal  int64
+	window     int64
+	windowSize int64
+}
+
+// PTSDuration returns the time covered by the presentation timestamps.
+// Timestamps are unwrapped, so this is correct across the 33 bit wrap around,
+// and the spans between timebase discontinuities are added up. Gaps from lost
+// signal are part of the duration, as they are in the remuxed timeline.
+func (p *PidStats) PTSDuration() time.Duration {
+	if !p.havePTS {
+		return 0
+	}
+	return ptsDuration(p.spanTotal + p.spanEnd - p.spanStart)
+}
+
+func (p *PidStats) pts(v int64) {
+	if !p.havePTS {
+		p.havePTS = true
+		p.FirstPTS, p.LastPTS = v, v
+		p.lastPTS, p.spanStart, p.spanEnd = v, v, v
+		p.ptsReset = false
+		return
+	}
+
+	// Unwrap relative to the previous timestamp on this PID. Timestamps are
+	// not monotonic with B-frames, so keep the lowest and highest.
+	delta := (v - p.lastPTS%ptsWrap + ptsWrap) % ptsWrap
+	if delta >= ptsWrap/2 {
+		delta -= ptsWrap
+	}
+
+	if p.ptsReset || delta < -maxPTSReorder {
+		// A new timebase, start a new span.
+		p.spanTotal += p.spanEnd - p.spanStart
+		p.spans++
+		p.ptsReset = false
+		p.lastPTS, p.spanStart, p.spanEnd = v, v, v
+		p.LastPTS = v
+		return
+	}
+	p.lastPTS += delta
+
+	if p.lastPTS < p.spanStart {
+		p.spanStart = p.lastPTS
+		if p.spans == 0 {
+			p.FirstPTS = p.lastPTS
+		}
+	}
+	if p.lastPTS > p.spanEnd {
+		p.spanEnd = p.lastPTS
+		p.LastPTS = p.lastPTS
+	}
+}
+
+// count adds a packet to the bitrate window for the current second.
+func (p *PidStats) count(second int64) {
+	if second != p.window {
+		p.flushWindow()
+		p.window, p.windowSize = second, 0
+	}
+	p.windowSize += ts.PktLen
+}
+
+func (p *PidStats) flushWindow() {
+	if rate := float64(p.windowSize * 8); rate > p.PeakBitrate {
+		p.PeakBitrate = rate
+	}
+}
+
+type Report struct {
+	Stats
+	NullPackets int64
+	Pids        map[int16]*PidStats
+}
+
+func (r *Report) NullRatio() float64 {
+	if r.Packets == 0 {
+		return 0
+	}
+	return float64(r.NullPackets) / float64(r.Packets)
+}
+
+// Health returns a score from 0 to 100. Every error per ten thousand
+// packets costs a point.
+func (r *Report) Health() int {
+	if r.Packets == 0 {
+		return 0
+	}
+
+	errors := r.Errors()
+	for _, p := range r.Pids {
+		errors += p.PCRDiscontinuities
+	}
+
+	score := 100 - int(errors*10000/r.Packets)
+	if score < 0 {
+		score = 0
+	}
+
+	return score
+}
+
+func (r *Report) SortedPids() []*PidStats {
+	pids := make([]*PidStats, 0, len(r.Pids))
+	for _, p := range r.Pids {
+		pids = append(pids, p)
+	}
+	sort.Slice(pids, func(i, j int) bool {
+		return pids[i].Pid < pids[j].Pid
+	})
+
+	return pids
+}
+
+type Analyzer struct {
+	report *Report
+	pos    int64
+	clock  int64
+}
+
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{
+		report: &Report{
+			Stats: Stats{PCRPid: -1},
+			Pids:  map[int16]*PidStats{},
+		},
+	}
+}
+
+func (a *Analyzer) SyncError() {
+	a.report.SyncErrors++
+}
+
+func (a *Analyzer) Packet(pkt ts.Pkt) {
+	r := a.report
+	pos := a.pos
+	a.pos += ts.PktLen
+
+	r.Packets++
+
+	pid := pkt.Pid()
+	p, ok := r.Pids[pid]
+	if !ok {
+		p = &PidStats{Pid: pid}
+		r.Pids[pid] = p
+	}
+	p.Packets++
+	p.count(a.clock / pcrClock)
+
+	if transportError(pkt) {
+		p.TransportErrors++
+		r.TransportErrors++
+		return
+	}
+
+	if pid == PidNull {
+		r.NullPackets++
+		return
+	}
+
+	cc := continuityCounter(pkt)
+	if p.haveCC && hasPayload(pkt) && !discontinuity(pkt) {
+		if cc != (p.lastCC+1)&0x0F && cc != p.lastCC {
+			p.ContinuityErrors++
+			r.ContinuityErrors++
+		}
+	}
+	p.lastCC, p.haveCC = cc, true
+
+	// The indicator may come on a packet of its own, before the new
+	// timestamps.
+	if discontinuity(pkt) {
+		p.ptsReset, p.pcrReset = true, true
+	}
+	if v, ok := pts(pkt); ok {
+		p.pts(v)
+	}
+
+	v, ok := pcr(pkt)
+	if !ok {
+		return
+	}
+	if r.PCRPid < 0 {
+		r.PCRPid = pid
+	}
+	p.PCRs++
+
+	disc := p.pcrReset
+	p.pcrReset = false
+	a.pcr(p, v, pos, disc)
+}
+
+func (a *Analyzer) pcr(p *PidStats, v, pos int64, disc bool) {
+	if !p.havePCR || disc {
+		p.havePCR = true
+		p.lastPCR, p.lastPCRPos = v, pos
+		p.pcrRate = 0
+		return
+	}
+
+	delta := v - p.lastPCR
+	if delta < 0 && p.lastPCR > pcrWrap/2 && v < pcrWrap/2 {
+		delta += pcrWrap
+	}
+
+	if delta < 0 {
+		// The clock was reset without the discontinuity indicator.
+		p.PCRDiscontinuities++
+		p.lastPCR, p.lastPCRPos = v, pos
+		p.pcrRate = 0
+		return
+	}
+
+	interval := pcrDuration(delta)
+	if interval > maxPCRInterval {
+		p.PCRIntervalErrors++
+	}
+	if interval > p.MaxPCRInterval {
+		p.MaxPCRInterval = interval
+	}
+
+	// Jitter is measured against the byte rate of the previous interval, so
+	// for partial transport streams it includes bitrate changes.
+	bytes := float64(pos - p.lastPCRPos)
+	if interval > maxPCRJump {
+		// Most likely lost signal, the time is still part of the recording.
+		p.PCRDiscontinuities++
+		p.pcrRate = 0
+	} else if p.pcrRate > 0 {
+		jitter := pcrDuration(int64(float64(delta) - bytes/p.pcrRate))
+		if jitter < 0 {
+			jitter = -jitter
+		}
+		if jitter > p.MaxPCRJitter {
+			p.MaxPCRJitter = jitter
+		}
+	}
+	if delta > 0 && interval <= maxPCRJump {
+		p.pcrRate = bytes / float64(delta)
+	}
+
+	p.elapsed += delta
+	p.Duration = pcrDuration(p.elapsed)
+	if p.Pid == a.report.PCRPid {
+		a.clock = p.elapsed
+	}
+	p.lastPCR, p.lastPCRPos = v, pos
+}
+
+func (a *Analyzer) Report() *Report {
+	r := a.report
+
+	if p, ok := r.Pids[r.PCRPid]; ok {
+		r.Duration = p.Duration
+		r.MaxGap = p.MaxPCRInterval
+	}
+
+	// The presentation timestamps give the exact duration, the PCR only
+	// covers the time between the first and last PCR.
+	var ptsDuration time.Duration
+	for _, p := range r.Pids {
+		p.flushWindow()
+		if d := p.PTSDuration(); d > ptsDuration {
+			ptsDuration = d
+		}
+	}
+	if ptsDuration > 0 {
+		r.Duration = ptsDuration
+	}
+
+	if r.Duration > 0 {
+		for _, p := range r.Pids {
+			p.AvgBitrate = float64(p.Packets*ts.PktLen*8) / r.Duration.Seconds()
+		}
+	}
+
+	return r
+}
+
+func AnalyzeFile(filename string) (*Report, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Analyze(file)
+}
+
+func Analyze(r io.Reader) (*Report, error) {
+	var buf [ts.PktLen]byte
+
+	a := NewAnalyzer()
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+
+	for {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				a.SyncError()
+				continue
+			}
+			return a.Report(), err
+		}
+		a.Packet(pkt)
+	}
+
+	return a.Report(), nil
+}
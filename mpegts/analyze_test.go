@@ -0,0 +1,85 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+// testSegment is a run of 30fps video frames on one timebase, with the PCR
+// in every frame.
+type testSegment struct {
+	start  int64
+	frames int
+	disc   bool
+	size   int
+}
+
+func segmentsStream(segments []testSegment) []byte {
+	var out []byte
+	cc := 0
+	for _, s := range segments {
+		for i := 0; i < s.frames; i++ {
+			pts := (s.start + int64(i)*3000) % ptsWrap
+			var flags byte
+			if s.disc && i == 0 {
+				flags = 0x80
+			}
+			size := s.size
+			if size == 0 {
+				size = 100
+			}
+			out = append(out, pesPackets(testVideoPid, &cc, pcrField(pts*300, flags), pesPacket(0xE0, pts, make([]byte, size)))...)
+		}
+	}
+	return out
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []testSegment
+		duration time.Duration
+		minPeak  float64
+	}{
+		{"continuous", []testSegment{{start: 0, frames: 301}}, 10 * time.Second, 0},
+		{"wrap", []testSegment{{start: ptsWrap - 5*ptsClock, frames: 301}}, 10 * time.Second, 0},
+		{"reset with indicator", []testSegment{{start: 1000 * ptsClock, frames: 151}, {start: 0, frames: 151, disc: true}}, 10 * time.Second, 0},
+		{"reset without indicator", []testSegment{{start: 1000 * ptsClock, frames: 151}, {start: 0, frames: 151}}, 10 * time.Second, 0},
+		{"lost signal", []testSegment{{start: 0, frames: 151}, {start: 65 * ptsClock, frames: 151}}, 70 * time.Second, 0},
+		// The last second, which never ends, is heavier than the rest.
+		{"peak in last second", []testSegment{{start: 0, frames: 61}, {start: 61 * 3000, frames: 29, size: 1800}},
+			89 * 3000 * time.Second / ptsClock, 280 * ts.PktLen * 8},
+	}
+
+	for _, tt := range tests {
+		report, err := Analyze(bytes.NewReader(segmentsStream(tt.segments)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if report.Duration != tt.duration {
+			t.Errorf("%s: duration %v, want %v", tt.name, report.Duration, tt.duration)
+		}
+		if p := report.Pids[testVideoPid]; p.PeakBitrate < tt.minPeak {
+			t.Errorf("%s: peak bitrate %v, want at least %v", tt.name, p.PeakBitrate, tt.minPeak)
+		}
+	}
+}
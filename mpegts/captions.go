@@ -0,0 +1,364 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+// CCData is one cc_data triplet from the ATSC A/53 caption data carried in
+// the video stream. Type is the cc_type: 0 and 1 for CEA-608 field 1 and 2,
+// 2 and 3 for CEA-708 DTVCC packet data.
+type CCData struct {
+	Time time.Duration
+	Type uint8
+	Data [2]byte
+}
+
+type ccPicture struct {
+	pts  int64
+	data []CCData
+}
+
+var ga94 = []byte("GA94")
+
+// parseCCData decodes the cc_data() structure that follows the GA94 user
+// data type code 0x03.
+func parseCCData(data []byte) []CCData {
+	if len(data) < 2 {
+		return nil
+	}
+	count := int(data[0] & 0x1F)
+	data = data[2:]
+
+	var cc []CCData
+	for i := 0; i < count && len(data) >= 3; i++ {
+		if data[0]&0x04 != 0 {
+			cc = append(cc, CCData{Type: data[0] & 0x03, Data: [2]byte{data[1], data[2]}})
+		}
+		data = data[3:]
+	}
+
+	return cc
+}
+
+// mpeg2Captions finds ATSC caption data in MPEG-2 video user_data.
+func mpeg2Captions(es []byte) []CCData {
+	var cc []CCData
+
+	for {
+		i := bytes.Index(es, []byte{0x00, 0x00, 0x01, 0xB2})
+		if i < 0 {
+			return cc
+		}
+		es = es[i+4:]
+
+		if len(es) >= 5 && bytes.Equal(es[0:4], ga94) && es[4] == 0x03 {
+			cc = append(cc, parseCCData(es[5:])...)
+		}
+	}
+}
+
+// nalUnits splits an Annex B byte stream into NAL units.
+func nalUnits(es []byte) [][]byte {
+	var units [][]byte
+
+	start := bytes.Index(es, []byte{0x00, 0x00, 0x01})
+	for start >= 0 {
+		es = es[start+3:]
+		next := bytes.Index(es, []byte{0x00, 0x00, 0x01})
+		if next < 0 {
+			units = append(units, es)
+			break
+		}
+		units = append(units, bytes.TrimRight(es[:next], "\x00"))
+		start = next
+	}
+
+	return units
+}
+
+// unescapeRBSP removes emulation prevention bytes.
+func unescapeRBSP(nal []byte) []byte {
+	out := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 0x03 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// seiMessages calls fn with the type and payload of each message in an
+// H.264 or HEVC SEI payload.
+func seiMessages(sei []byte, fn func(payloadType int, p []byte)) {
+	for len(sei) > 2 {
+		payloadType := 0
+		for len(sei) > 0 && sei[0] == 0xFF {
+			payloadType += 255
+			sei = sei[1:]
+		}
+		if len(sei) == 0 {
+			break
+		}
+		payloadType += int(sei[0])
+		sei = sei[1:]
+
+		size := 0
+		for len(sei) > 0 && sei[0] == 0xFF {
+			size += 255
+			sei = sei[1:]
+		}
+		if len(sei) == 0 {
+			break
+		}
+		size += int(sei[0])
+		sei = sei[1:]
+		if size > len(sei) {
+			break
+		}
+
+		fn(payloadType, sei[:size])
+		sei = sei[size:]
+	}
+}
+
+// seiCaptions finds ATSC caption data in the user_data_registered_itu_t_t35
+// SEI messages of an H.264 or HEVC SEI payload.
+func seiCaptions(sei []byte) []CCData {
+	var cc []CCData
+
+	seiMessages(sei, func(payloadType int, p []byte) {
+		if payloadType == 4 && len(p) >= 8 && p[0] == 0xB5 && p[1] == 0x00 && p[2] == 0x31 &&
+			bytes.Equal(p[3:7], ga94) && p[7] == 0x03 {
+			cc = append(cc, parseCCData(p[8:])...)
+		}
+	})
+
+	return cc
+}
+
+func h264Captions(es []byte) []CCData {
+	var cc []CCData
+	for _, nal := range nalUnits(es) {
+		if len(nal) > 1 && nal[0]&0x1F == 6 {
+			cc = append(cc, seiCaptions(unescapeRBSP(nal[1:]))...)
+		}
+	}
+	return cc
+}
+
+func hevcCaptions(es []byte) []CCData {
+	var cc []CCData
+	for _, nal := range nalUnits(es) {
+		if len(nal) > 2 && (nal[0]>>1&0x3F == 39 || nal[0]>>1&0x3F == 40) {
+			cc = append(cc, seiCaptions(unescapeRBSP(nal[2:]))...)
+		}
+	}
+	return cc
+}
+
+// pesReader reassembles the PES packets of a single PID.
+type pesReader struct {
+	buf     []byte
+	started bool
+}
+
+// push adds pkt and returns the PES packet it completed, if any.
+func (r *pesReader) push(pkt ts.Pkt) []byte {
+	var done []byte
+
+	if payloadUnitStart(pkt) {
+		if r.started {
+			done = r.buf
+		}
+		r.buf = nil
+		r.started = true
+	}
+	if r.started {
+		r.buf = append(r.buf, payload(pkt)...)
+	}
+
+	return done
+}
+
+func (r *pesReader) flush() []byte {
+	if !r.started {
+		return nil
+	}
+	r.started = false
+	return r.buf
+}
+
+// parsePES returns the presentation timestamp and elementary stream data of
+// a PES packet.
+func parsePES(pes []byte) (int64, bool, []byte) {
+	if len(pes) < 9 || pes[0] != 0x00 || pes[1] != 0x00 || pes[2] != 0x01 {
+		return 0, false, nil
+	}
+	end := 9 + int(pes[8])
+	if end > len(pes) {
+		return 0, false, nil
+	}
+	if pes[7]&0x80 == 0 || len(pes) < 14 {
+		return 0, false, pes[end:]
+	}
+
+	return parseTimestamp(pes[9:14]), true, pes[end:]
+}
+
+func ReadCaptionDataFile(filename string) ([]CCData, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadCaptionData(file)
+}
+
+// ReadCaptionData extracts the closed caption data of the first video stream
+// in presentation order. Times are relative to the first picture.
+func ReadCaptionData(r io.Reader) ([]CCData, error) {
+	var (
+		buf      [ts.PktLen]byte
+		video    *Stream
+		pictures []ccPicture
+		pes      pesReader
+	)
+
+	programs := newProgramReader()
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+
+	picture := func(data []byte) {
+		pts, ok, es := parsePES(data)
+		if !ok {
+			return
+		}
+
+		var cc []CCData
+		switch video.Type {
+		case StreamMPEG2Video:
+			cc = mpeg2Captions(es)
+		case StreamH264:
+			cc = h264Captions(es)
+		case StreamHEVC:
+			cc = hevcCaptions(es)
+		}
+		pictures = append(pictures, ccPicture{pts: pts, data: cc})
+	}
+
+	for {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				continue
+			}
+			return nil, err
+		}
+		if transportError(pkt) {
+			continue
+		}
+
+		if video == nil {
+			programs.push(pkt)
+			if programs.done() {
+				video = firstVideoStream(programs.list())
+				if video == nil {
+					return nil, nil
+				}
+			}
+			continue
+		}
+
+		if pkt.Pid() == video.Pid {
+			if data := pes.push(pkt); data != nil {
+				picture(data)
+			}
+		}
+	}
+
+	if video == nil {
+		return nil, ErrNoPAT
+	}
+	if data := pes.flush(); data != nil {
+		picture(data)
+	}
+
+	return orderCaptions(pictures), nil
+}
+
+func firstVideoStream(programs []*Program) *Stream {
+	for _, p := range programs {
+		for _, s := range p.Streams {
+			if s.IsVideo() {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// orderCaptions sorts pictures from decode into presentation order and
+// timestamps their caption data.
+func orderCaptions(pictures []ccPicture) []CCData {
+	if len(pictures) == 0 {
+		return nil
+	}
+
+	// Unwrap the timestamps relative to the first picture.
+	first := pictures[0].pts
+	for i := range pictures {
+		delta := (pictures[i].pts - first + ptsWrap) % ptsWrap
+		if delta >= ptsWrap/2 {
+			delta -= ptsWrap
+		}
+		pictures[i].pts = delta
+	}
+
+	sort.SliceStable(pictures, func(i, j int) bool {
+		return pictures[i].pts < pictures[j].pts
+	})
+
+	base := pictures[0].pts
+	var cc []CCData
+	for _, p := range pictures {
+		for _, d := range p.data {
+			d.Time = ptsDuration(p.pts - base)
+			cc = append(cc, d)
+		}
+	}
+
+	return cc
+}
@@ -0,0 +1,558 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+// Give up looking for guide data after this many packets, about a minute of
+// a typical HD channel. ETTs are only repeated every minute or so.
+const maxGuidePackets = 400000
+
+const (
+	pidPSIP = 0x1FFB
+	pidSDT  = 0x0011
+	pidEIT  = 0x0012
+	pidTDT  = 0x0014
+
+	tableMGT  = 0xC7
+	tableTVCT = 0xC8
+	tableCVCT = 0xC9
+	tableEIT  = 0xCB
+	tableETT  = 0xCC
+	tableSTT  = 0xCD
+
+	tableSDT      = 0x42
+	tableDVBEIT   = 0x4E
+	tableTDT      = 0x70
+	tableTOT      = 0x73
+	descService   = 0x48
+	descShortEvnt = 0x4D
+)
+
+var (
+	ErrNoGuide = errors.New("No guide data found")
+	ErrNoTime  = errors.New("No broadcast time found")
+)
+
+var gpsEpoch = time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC)
+
+type Event struct {
+	ID          uint16
+	Title       string
+	Description string
+	Start       time.Time
+	Duration    time.Duration
+}
+
+func (e *Event) End() time.Time {
+	return e.Start.Add(e.Duration)
+}
+
+// GuideInfo is the program information broadcast in the ATSC PSIP or DVB SI
+// tables of a stream.
+type GuideInfo struct {
+	ChannelName   string
+	ChannelNumber string
+	Time          time.Time
+	Event         *Event
+}
+
+type guideReader struct {
+	readers       map[int16]*sectionReader
+	programNumber uint16
+	sourceID      uint16
+	haveChannel   bool
+	gpsOffset     int64
+	events        map[uint16]*Event
+	texts         map[uint16]string
+	info          GuideInfo
+	complete      bool
+}
+
+func newGuideReader() *guideReader {
+	g := &guideReader{
+		readers: map[int16]*sectionReader{},
+		events:  map[uint16]*Event{},
+		texts:   map[uint16]string{},
+	}
+	for _, pid := range []int16{PidPAT, pidPSIP, pidSDT, pidEIT, pidTDT} {
+		g.readers[pid] = &sectionReader{}
+	}
+	return g
+}
+
+func (g *guideReader) push(pkt ts.Pkt) {
+	reader, ok := g.readers[pkt.Pid()]
+	if !ok || transportError(pkt) {
+		return
+	}
+
+	sections, _ := reader.push(pkt)
+	for _, s := range sections {
+		g.section(s)
+	}
+	if len(sections) > 0 {
+		g.complete = g.isComplete()
+	}
+}
+
+func (g *guideReader) section(s Section) {
+	switch s.TableID() {
+	case tablePAT:
+		for number := range parsePAT(s) {
+			if g.programNumber == 0 || number < g.programNumber {
+				g.programNumber = number
+			}
+		}
+	case tableMGT:
+		g.parseMGT(s.Data())
+	case tableTVCT, tableCVCT:
+		g.parseVCT(s.Data())
+	case tableSTT:
+		g.parseSTT(s.Data())
+	case tableEIT:
+		if g.haveChannel && s.TableIDExtension() == g.sourceID {
+			g.parseEIT(s.Data())
+		}
+	case tableETT:
+		g.parseETT(s.Data())
+	case tableSDT:
+		g.parseSDT(s.Data())
+	case tableDVBEIT:
+		if s.TableIDExtension() == g.programNumber && s.SectionNumber() == 0 {
+			g.parseDVBEIT(s.Data())
+		}
+	case tableTDT, tableTOT:
+		if t, ok := dvbTime(s[3:]); ok && g.info.Time.IsZero() {
+			g.info.Time = t
+		}
+	}
+}
+
+func (g *guideReader) parseMGT(data []byte) {
+	if len(data) < 3 {
+		return
+	}
+	n := int(data[1])<<8 | int(data[2])
+	data = data[3:]
+
+	for i := 0; i < n && len(data) >= 11; i++ {
+		tableType := int(data[0])<<8 | int(data[1])
+		pid := int16(data[2]&0x1F)<<8 | int16(data[3])
+		descLen := int(data[9]&0x0F)<<8 | int(data[10])
+
+		// EIT-0 and its ETT describe the current events.
+		if tableType == 0x0100 || tableType == 0x0200 {
+			if _, ok := g.readers[pid]; !ok {
+				g.readers[pid] = &sectionReader{}
+			}
+		}
+
+		if 11+descLen > len(data) {
+			return
+		}
+		data = data[11+descLen:]
+	}
+}
+
+func (g *guideReader) parseVCT(data []byte) {
+	if len(data) < 2 || g.programNumber == 0 {
+		return
+	}
+	n := int(data[1])
+	data = data[2:]
+
+	for i := 0; i < n && len(data) >= 32; i++ {
+		descLen := int(data[30]&0x03)<<8 | int(data[31])
+		program := uint16(data[24])<<8 | uint16(data[25])
+
+		if program == g.programNumber {
+			var name []uint16
+			for j := 0; j < 14; j += 2 {
+				if c := uint16(data[j])<<8 | uint16(data[j+1]); c != 0 {
+					name = append(name, c)
+				}
+			}
+			major := int(data[14]&0x0F)<<6 | int(data[15])>>2
+			minor := int(data[15]&0x03)<<8 | int(data[16])
+
+			g.info.ChannelName = string(utf16.Decode(name))
+			g.info.ChannelNumber = fmt.Sprintf("%d.%d", major, minor)
+			g.sourceID = uint16(data[28])<<8 | uint16(data[29])
+			g.haveChannel = true
+			return
+		}
+
+		if 32+descLen > len(data) {
+			return
+		}
+		data = data[32+descLen:]
+	}
+}
+
+func (g *guideReader) gpsTime(seconds uint32) time.Time {
+	return gpsEpoch.Add(time.Duration(int64(seconds)-g.gpsOffset) * time.Second)
+}
+
+func (g *guideReader) parseSTT(data []byte) {
+	if len(data) < 6 || !g.info.Time.IsZero() {
+		return
+	}
+	g.gpsOffset = int64(data[5])
+	g.info.Time = g.gpsTime(uint32(data[1])<<24 | uint32(data[2])<<16 | uint32(data[3])<<8 | uint32(data[4]))
+}
+
+func (g *guideReader) parseEIT(data []byte) {
+	if len(data) < 2 {
+		return
+	}
+	n := int(data[1])
+	data = data[2:]
+
+	for i := 0; i < n && len(data) >= 10; i++ {
+		e := &Event{
+			ID:       uint16(data[0]&0x3F)<<8 | uint16(data[1]),
+			Start:    g.gpsTime(uint32(data[2])<<24 | uint32(data[3])<<16 | uint32(data[4])<<8 | uint32(data[5])),
+			Duration: time.Duration(int(data[6]&0x0F)<<16|int(data[7])<<8|int(data[8])) * time.Second,
+		}
+
+		titleLen := int(data[9])
+		if 10+titleLen+2 > len(data) {
+			return
+		}
+		e.Title = multipleString(data[10 : 10+titleLen])
+		data = data[10+titleLen:]
+
+		descLen := int(data[0]&0x0F)<<8 | int(data[1])
+		if 2+descLen > len(data) {
+			return
+		}
+		data = data[2+descLen:]
+
+		g.events[e.ID] = e
+	}
+}
+
+func (g *guideReader) parseETT(data []byte) {
+	if len(data) < 5 {
+		return
+	}
+
+	etmID := uint32(data[1])<<24 | uint32(data[2])<<16 | uint32(data[3])<<8 | uint32(data[4])
+	if !g.haveChannel || uint16(etmID>>16) != g.sourceID || etmID&0x03 != 0x02 {
+		return
+	}
+
+	g.texts[uint16(etmID>>2&0x3FFF)] = multipleString(data[5:])
+}
+
+// multipleString decodes the first string of an ATSC multiple_string_structure.
+// Huffman compressed strings are not supported.
+func multipleString(data []byte) string {
+	if len(data) < 5 || data[0] == 0 {
+		return ""
+	}
+	segments := int(data[4])
+	data = data[5:]
+
+	var s strings.Builder
+	for i := 0; i < segments && len(data) >= 3; i++ {
+		compression, mode, n := data[0], data[1], int(data[2])
+		if 3+n > len(data) {
+			break
+		}
+		text := data[3 : 3+n]
+		data = data[3+n:]
+
+		if compression != 0 {
+			continue
+		}
+
+		switch {
+		case mode == 0x3F:
+			var u []uint16
+			for j := 0; j+1 < len(text); j += 2 {
+				u = append(u, uint16(text[j])<<8|uint16(text[j+1]))
+			}
+			s.WriteString(string(utf16.Decode(u)))
+		case mode <= 0x06:
+			// Latin-1 and the other modes give the high byte of a code point.
+			for _, c := range text {
+				s.WriteRune(rune(mode)<<8 | rune(c))
+			}
+		}
+	}
+
+	return strings.TrimSpace(s.String())
+}
+
+func (g *guideReader) parseSDT(data []byte) {
+	if len(data) < 3 || g.programNumber == 0 {
+		return
+	}
+	data = data[3:]
+
+	for len(data) >= 5 {
+		serviceID := uint16(data[0])<<8 | uint16(data[1])
+		descLen := int(data[3]&0x0F)<<8 | int(data[4])
+		if 5+descLen > len(data) {
+			return
+		}
+
+		if serviceID == g.programNumber {
+			for _, d := range parseDescriptors(data[5 : 5+descLen]) {
+				if d.Tag != descService || len(d.Data) < 2 {
+					continue
+				}
+				providerLen := int(d.Data[1])
+				if 2+providerLen+1 > len(d.Data) {
+					continue
+				}
+				nameLen := int(d.Data[2+providerLen])
+				if 3+providerLen+nameLen > len(d.Data) {
+					continue
+				}
+				g.info.ChannelName = dvbString(d.Data[3+providerLen : 3+providerLen+nameLen])
+				g.info.ChannelNumber = fmt.Sprint(serviceID)
+			}
+			return
+		}
+
+		data = data[5+descLen:]
+	}
+}
+
+func (g *guideReader) parseDVBEIT(data []byte) {
+	if len(data) < 6 || g.info.Event != nil {
+		return
+	}
+	data = data[6:]
+
+	if len(data) < 12 {
+		return
+	}
+	e := &Event{ID: uint16(data[0])<<8 | uint16(data[1])}
+	e.Start, _ = dvbTime(data[2:7])
+	e.Duration = time.Duration(bcd(data[7])*3600+bcd(data[8])*60+bcd(data[9])) * time.Second
+
+	descLen := int(data[10]&0x0F)<<8 | int(data[11])
+	if 12+descLen > len(data) {
+		return
+	}
+	for _, d := range parseDescriptors(data[12 : 12+descLen]) {
+		if d.Tag != descShortEvnt || len(d.Data) < 4 {
+			continue
+		}
+		nameLen := int(d.Data[3])
+		if 4+nameLen+1 > len(d.Data) {
+			continue
+		}
+		e.Title = dvbString(d.Data[4 : 4+nameLen])
+		textLen := int(d.Data[4+nameLen])
+		if 5+nameLen+textLen <= len(d.Data) {
+			e.Description = dvbString(d.Data[5+nameLen : 5+nameLen+textLen])
+		}
+	}
+
+	g.info.Event = e
+}
+
+func bcd(b byte) int {
+	return int(b>>4)*10 + int(b&0x0F)
+}
+
+// dvbTime decodes a DVB UTC time, a modified Julian date followed by the time
+// of day in BCD.
+func dvbTime(data []byte) (time.Time, bool) {
+	if len(data) < 5 {
+		return time.Time{}, false
+	}
+	mjd := int(data[0])<<8 | int(data[1])
+	if mjd == 0xFFFF {
+		return time.Time{}, false
+	}
+
+	t := time.Date(1858, 11, 17, bcd(data[2]), bcd(data[3]), bcd(data[4]), 0, time.UTC)
+	return t.AddDate(0, 0, mjd), true
+}
+
+// dvbString decodes a DVB SI string. Only the default table, which we treat
+// as Latin-1, and UTF-8 are supported.
+func dvbString(data []byte) string {
+	if len(data) > 0 && data[0] < 0x20 {
+		utf8 := data[0] == 0x15
+		data = data[1:]
+		if utf8 {
+			return strings.TrimSpace(string(data))
+		}
+	}
+
+	var s strings.Builder
+	for _, c := range data {
+		if c >= 0x20 && (c < 0x80 || c >= 0xA0) {
+			s.WriteRune(rune(c))
+		}
+	}
+
+	return strings.TrimSpace(s.String())
+}
+
+// isComplete reports whether we have the time and an event with a
+// description, there is no need to read any further.
+func (g *guideReader) isComplete() bool {
+	if g.info.Time.IsZero() {
+		return false
+	}
+
+	e := g.currentEvent()
+	return e != nil && e.Description != ""
+}
+
+// currentEvent picks the event airing at the stream time. Recordings are
+// usually padded, so the event overlapping most of the following half hour
+// is preferred over one that is about to end.
+func (g *guideReader) currentEvent() *Event {
+	if g.info.Event != nil || g.info.Time.IsZero() {
+		return g.info.Event
+	}
+
+	var best *Event
+	var bestOverlap time.Duration
+	start := g.info.Time
+	end := start.Add(30 * time.Minute)
+	for _, e := range g.events {
+		s, f := e.Start, e.End()
+		if s.Before(start) {
+			s = start
+		}
+		if f.After(end) {
+			f = end
+		}
+		if overlap := f.Sub(s); overlap > bestOverlap {
+			best, bestOverlap = e, overlap
+		}
+	}
+
+	if best != nil {
+		if text, ok := g.texts[best.ID]; ok {
+			best.Description = text
+		}
+	}
+
+	return best
+}
+
+func ReadGuideInfoFile(filename string) (*GuideInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadGuideInfo(file)
+}
+
+// ReadGuideInfo recovers the channel and current program from the ATSC PSIP
+// (VCT, EIT, ETT, STT) or DVB SI (SDT, EIT, TDT) tables carried in a stream.
+func ReadGuideInfo(r io.Reader) (*GuideInfo, error) {
+	var buf [ts.PktLen]byte
+
+	g := newGuideReader()
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+
+	for i := 0; i < maxGuidePackets && !g.complete; i++ {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				continue
+			}
+			return nil, err
+		}
+		g.push(pkt)
+	}
+
+	g.info.Event = g.currentEvent()
+	if g.info.Event == nil || g.info.Event.Title == "" {
+		return nil, ErrNoGuide
+	}
+
+	return &g.info, nil
+}
+
+func ReadStreamStartFile(filename string) (time.Time, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	return ReadStreamStart(file)
+}
+
+// ReadStreamStart returns the broadcast time at the start of a stream, the
+// first STT or TDT time less the program clock elapsed before it.
+func ReadStreamStart(r io.Reader) (time.Time, error) {
+	var buf [ts.PktLen]byte
+
+	g := newGuideReader()
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+
+	pcrPid := PidNull
+	var firstPCR, elapsed int64
+	for i := 0; i < maxGuidePackets; i++ {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				continue
+			}
+			return time.Time{}, err
+		}
+
+		if v, ok := pcr(pkt); ok && !transportError(pkt) {
+			if pcrPid == PidNull {
+				pcrPid, firstPCR = pkt.Pid(), v
+			} else if pkt.Pid() == pcrPid {
+				if elapsed = v - firstPCR; elapsed < 0 {
+					elapsed += pcrWrap
+				}
+			}
+		}
+
+		g.push(pkt)
+		if !g.info.Time.IsZero() {
+			return g.info.Time.Add(-pcrDuration(elapsed)), nil
+		}
+	}
+
+	return time.Time{}, ErrNoTime
+}
@@ -0,0 +1,266 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	testEITPid    = 0x1D00
+	testETTPid    = 0x1E00
+	testSourceID  = 5
+	testGPSOffset = 18
+)
+
+func gpsSeconds(t time.Time) []byte {
+	s := uint32(t.Sub(gpsEpoch)/time.Second) + testGPSOffset
+	return []byte{byte(s >> 24), byte(s >> 16), byte(s >> 8), byte(s)}
+}
+
+// atscString returns a multiple_string_structure holding s in Latin-1.
+func atscString(s string) []byte {
+	return append([]byte{1, 'e', 'n', 'g', 1, 0, 0, byte(len(s))}, s...)
+}
+
+func mgtSection() []byte {
+	body := []byte{0, 0, 2,
+		0x01, 0x00, 0xE0 | testEITPid>>8, testEITPid & 0xFF, 0xC0, 0, 0, 0, 0, 0xF0, 0,
+		0x02, 0x00, 0xE0 | testETTPid>>8, testETTPid & 0xFF, 0xC0, 0, 0, 0, 0, 0xF0, 0,
+		0xF0, 0}
+	return buildSection(tableMGT, 0, 0, body)
+}
+
+func vctChannel(name string, major, minor int, program, source uint16) []byte {
+	ch := make([]byte, 32)
+	for i, c := range utf16.Encode([]rune(name)) {
+		ch[2*i], ch[2*i+1] = byte(c>>8), byte(c)
+	}
+	ch[14] = 0xF0 | byte(major>>6)
+	ch[15] = byte(major<<2) | byte(minor>>8)
+	ch[16] = byte(minor)
+	ch[24], ch[25] = byte(program>>8), byte(program)
+	ch[28], ch[29] = byte(source>>8), byte(source)
+	ch[30] = 0xFC
+	return ch
+}
+
+func tvctSection() []byte {
+	body := []byte{0, 2}
+	body = append(body, vctChannel("KQED-SD", 9, 2, 2, testSourceID+1)...)
+	body = append(body, vctChannel("KQED-HD", 9, 1, 1, testSourceID)...)
+	return buildSection(tableTVCT, 1, 0, append(body, 0xFC, 0))
+}
+
+func sttSection(t time.Time) []byte {
+	return buildSection(tableSTT, 0, 0, append(append([]byte{0}, gpsSeconds(t)...), testGPSOffset, 0, 0))
+}
+
+type testEvent struct {
+	id       uint16
+	title    string
+	start    time.Time
+	duration time.Duration
+}
+
+func eitSection(source uint16, events []testEvent) []byte {
+	body := []byte{0, byte(len(events))}
+	for _, e := range events {
+		d := int(e.duration / time.Second)
+		title := atscString(e.title)
+		body = append(body, 0xC0|byte(e.id>>8), byte(e.id))
+		body = append(body, gpsSeconds(e.start)...)
+		body = append(body, 0xC0|byte(d>>16), byte(d>>8), byte(d), byte(len(title)))
+		body = append(body, title...)
+		body = append(body, 0xF0, 0)
+	}
+	return buildSection(tableEIT, source, 0, body)
+}
+
+func ettSection(source, event uint16, text string) []byte {
+	etm := uint32(source)<<16 | uint32(event)<<2 | 0x02
+	body := []byte{0, byte(etm >> 24), byte(etm >> 16), byte(etm >> 8), byte(etm)}
+	return buildSection(tableETT, 0, 0, append(body, atscString(text)...))
+}
+
+func sdtSection(service uint16, provider, name string) []byte {
+	desc := []byte{descService, byte(3 + len(provider) + len(name)), 0x01, byte(len(provider))}
+	desc = append(desc, provider...)
+	desc = append(desc, byte(len(name)))
+	desc = append(desc, name...)
+
+	body := []byte{0, 1, 0xFF, byte(service >> 8), byte(service), 0xFC, 0x80 | byte(len(desc)>>8), byte(len(desc))}
+	return buildSection(tableSDT, 1, 0, append(body, desc...))
+}
+
+// dvbTimeField encodes t as a modified Julian date and BCD time of day.
+func dvbTimeField(t time.Time) []byte {
+	mjd := int(t.Sub(time.Date(1858, 11, 17, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+	bcd := func(n int) byte { return byte(n/10<<4 | n%10) }
+	return []byte{byte(mjd >> 8), byte(mjd), bcd(t.Hour()), bcd(t.Minute()), bcd(t.Second())}
+}
+
+func tdtSection(t time.Time) []byte {
+	return append([]byte{tableTDT, 0x70, 5}, dvbTimeField(t)...)
+}
+
+func dvbEITSection(service uint16, title, text string, start time.Time, duration time.Duration) []byte {
+	desc := []byte{descShortEvnt, byte(5 + len(title) + len(text)), 'e', 'n', 'g', byte(len(title))}
+	desc = append(desc, title...)
+	desc = append(desc, byte(len(text)))
+	desc = append(desc, text...)
+
+	body := []byte{0, 1, 0, 1, 0, tableDVBEIT, 0, 1}
+	body = append(body, dvbTimeField(start)...)
+	d := duration.Round(time.Second)
+	body = append(body, dvbTimeField(time.Date(2000, 1, 1, int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, 0, time.UTC))[2:]...)
+	body = append(body, 0x80|byte(len(desc)>>8), byte(len(desc)))
+	return buildSection(tableDVBEIT, service, 0, append(body, desc...))
+}
+
+func TestReadGuideInfo(t *testing.T) {
+	now := time.Date(2024, 3, 1, 19, 58, 0, 0, time.UTC)
+	show := now.Add(2 * time.Minute)
+
+	psip := func(pid int16, section []byte) []byte {
+		return sectionPacket(pid, 0, section)
+	}
+
+	tests := []struct {
+		name    string
+		packets [][]byte
+		info    *GuideInfo
+	}{
+		{"ATSC", [][]byte{
+			patPacket(0),
+			psip(pidPSIP, mgtSection()),
+			psip(pidPSIP, tvctSection()),
+			psip(pidPSIP, sttSection(now)),
+			psip(testEITPid, eitSection(testSourceID+1, []testEvent{{7, "Other Channel", show, time.Hour}})),
+			psip(testEITPid, eitSection(testSourceID, []testEvent{
+				{1, "Ending", show.Add(-time.Hour), time.Hour},
+				{2, "Nature", show, time.Hour},
+			})),
+			psip(testETTPid, ettSection(testSourceID, 2, "Penguins of the Antarctic. ")),
+		}, &GuideInfo{
+			ChannelName:   "KQED-HD",
+			ChannelNumber: "9.1",
+			Time:          now,
+			Event:         &Event{ID: 2, Title: "Nature", Description: "Penguins of the Antarctic.", Start: show, Duration: time.Hour},
+		}},
+		{"DVB", [][]byte{
+			patPacket(0),
+			psip(pidSDT, sdtSection(1, "BBC", "BBC ONE")),
+			psip(pidTDT, tdtSection(now)),
+			psip(pidEIT, dvbEITSection(2, "Wrong Service", "", show, time.Hour)),
+			psip(pidEIT, dvbEITSection(1, "\x15Doctor Who", "\x15The Doctor returns.", show, 90*time.Minute)),
+		}, &GuideInfo{
+			ChannelName:   "BBC ONE",
+			ChannelNumber: "1",
+			Time:          now,
+			Event:         &Event{ID: 1, Title: "Doctor Who", Description: "The Doctor returns.", Start: show, Duration: 90 * time.Minute},
+		}},
+		{"no events for the channel", [][]byte{
+			patPacket(0),
+			psip(pidPSIP, mgtSection()),
+			psip(pidPSIP, tvctSection()),
+			psip(pidPSIP, sttSection(now)),
+			psip(testEITPid, eitSection(testSourceID+1, []testEvent{{7, "Other Channel", show, time.Hour}})),
+		}, nil},
+		{"nothing", [][]byte{patPacket(0)}, nil},
+	}
+
+	for _, tt := range tests {
+		info, err := ReadGuideInfo(bytes.NewReader(bytes.Join(tt.packets, nil)))
+		if tt.info == nil {
+			if err != ErrNoGuide {
+				t.Errorf("%s: got %+v %v, want %v", tt.name, info, err, ErrNoGuide)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if info.ChannelName != tt.info.ChannelName || info.ChannelNumber != tt.info.ChannelNumber || !info.Time.Equal(tt.info.Time) {
+			t.Errorf("%s: got %q %q %v, want %q %q %v", tt.name, info.ChannelName, info.ChannelNumber, info.Time,
+				tt.info.ChannelName, tt.info.ChannelNumber, tt.info.Time)
+		}
+		if e, want := info.Event, tt.info.Event; e.ID != want.ID || e.Title != want.Title || e.Description != want.Description ||
+			!e.Start.Equal(want.Start) || e.Duration != want.Duration {
+			t.Errorf("%s: event %+v, want %+v", tt.name, e, want)
+		}
+	}
+}
+
+func TestReadStreamStart(t *testing.T) {
+	now := time.Date(2024, 3, 1, 19, 58, 0, 0, time.UTC)
+	pcrPacket := func(cc int, pcr int64) []byte {
+		return tsPacket(testVideoPid, false, cc, pcrField(pcr, 0), nil)
+	}
+
+	tests := []struct {
+		name    string
+		packets [][]byte
+		start   time.Time
+		err     error
+	}{
+		{"STT", [][]byte{
+			pcrPacket(0, 1000*pcrClock),
+			pcrPacket(1, 1003*pcrClock+pcrClock/2),
+			sectionPacket(pidPSIP, 0, sttSection(now)),
+		}, now.Add(-3500 * time.Millisecond), nil},
+		{"TDT across the wrap", [][]byte{
+			pcrPacket(0, pcrWrap-pcrClock),
+			pcrPacket(1, pcrClock),
+			sectionPacket(pidTDT, 0, tdtSection(now)),
+			pcrPacket(2, 5*pcrClock),
+		}, now.Add(-2 * time.Second), nil},
+		{"no PCR", [][]byte{sectionPacket(pidTDT, 0, tdtSection(now))}, now, nil},
+		{"no time", [][]byte{patPacket(0), pcrPacket(0, 0)}, time.Time{}, ErrNoTime},
+	}
+
+	for _, tt := range tests {
+		start, err := ReadStreamStart(bytes.NewReader(bytes.Join(tt.packets, nil)))
+		if err != tt.err || !start.Equal(tt.start) {
+			t.Errorf("%s: got %v %v, want %v %v", tt.name, start, err, tt.start, tt.err)
+		}
+	}
+}
+
+func TestGuideStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"ATSC Latin-1", multipleString(atscString("Caf\xe9 ")), "Café"},
+		{"ATSC UTF-16", multipleString([]byte{1, 'e', 'n', 'g', 1, 0, 0x3F, 4, 0x00, 'H', 0x20, 0xAC}), "H€"},
+		{"ATSC compressed", multipleString([]byte{1, 'e', 'n', 'g', 1, 1, 0, 2, 0xAB, 0xCD}), ""},
+		{"ATSC no strings", multipleString([]byte{0}), ""},
+		{"DVB default", dvbString([]byte("News\x8a at Ten")), "News at Ten"},
+		{"DVB UTF-8", dvbString([]byte("\x15Caf\xc3\xa9")), "Café"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
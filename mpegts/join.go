@@ -0,0 +1,231 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+const (
+	// Gaps between parts shorter than this are not reported.
+	minJoinGap = time.Second
+	// Parts further apart than this don't share a clock with the previous
+	// part and start a new timebase.
+	maxJoinGap     = time.Hour
+	maxJoinOverlap = 10 * time.Minute
+	// Start a part at the next PES start if no random access point is
+	// flagged this long after the end of the previous part.
+	maxRandomAccessWait = 2 * time.Second
+)
+
+// Gap is a stretch missing between two joined parts, on the timeline of the
+// joined stream.
+type Gap struct {
+	Start    time.Duration
+	Duration time.Duration
+}
+
+type JoinResult struct {
+	Packets int64
+	Skipped int64
+	Gaps    []Gap
+	// Parts that didn't share a clock with the part before them.
+	Unaligned int
+}
+
+type joiner struct {
+	w       *bufio.Writer
+	result  JoinResult
+	havePTS bool
+	// The joined timeline is made of spans that share a clock, as the
+	// analyzer sees it.
+	offset    int64
+	spanStart int64
+	lastPTS   int64
+	// Last continuity counter written on each PID.
+	cc map[int16]int
+}
+
+// writeDiscontinuity writes an adaptation field only packet on pid with the
+// discontinuity indicator set, the following timestamps start a new timebase.
+func (j *joiner) writeDiscontinuity(pid int16) error {
+	var pkt [ts.PktLen]byte
+
+	for i := range pkt {
+		pkt[i] = 0xFF
+	}
+	pkt[0] = 0x47
+	pkt[1] = byte(pid>>8) & 0x1F
+	pkt[2] = byte(pid)
+	pkt[3] = 0x20 | byte(j.cc[pid])
+	pkt[4] = ts.PktLen - 5
+	pkt[5] = 0x80
+
+	if _, err := j.w.Write(pkt[:]); err != nil {
+		return err
+	}
+	j.result.Packets++
+	return nil
+}
+
+func (j *joiner) append(filename string, first bool) error {
+	var buf [ts.PktLen]byte
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	programs, err := ReadPrograms(file)
+	if err != nil {
+		return err
+	}
+	video := firstVideoStream(programs)
+	if video == nil {
+		return fmt.Errorf("No video stream in %q", filename)
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	started := !j.havePTS
+	unaligned := false
+	// Continuity counters of this part are shifted to follow on from the
+	// previous part, keeping any errors within the part.
+	shift := map[int16]int{}
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(file))
+	for {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				continue
+			}
+			return err
+		}
+
+		pid := pkt.Pid()
+		if pid == PidHDHRMeta && !first {
+			continue
+		}
+
+		v, isPTS := int64(0), false
+		if pid == video.Pid {
+			v, isPTS = pts(pkt)
+		}
+
+		if !started {
+			if !isPTS {
+				j.result.Skipped++
+				continue
+			}
+
+			d := ptsDiff(v, j.lastPTS)
+			switch {
+			case d > int64(maxJoinGap/time.Second)*ptsClock || d < -int64(maxJoinOverlap/time.Second)*ptsClock:
+				j.result.Unaligned++
+				j.offset += ptsDiff(j.lastPTS, j.spanStart)
+				j.spanStart, j.lastPTS = v, v
+				unaligned = true
+			case d > 0 && (randomAccess(pkt) || ptsDuration(d) > maxRandomAccessWait):
+				if gap := ptsDuration(d); gap > minJoinGap {
+					start := ptsDuration(j.offset + ptsDiff(j.lastPTS, j.spanStart))
+					j.result.Gaps = append(j.result.Gaps, Gap{Start: start, Duration: gap})
+				}
+			default:
+				j.result.Skipped++
+				continue
+			}
+			started = true
+		}
+
+		if isPTS {
+			if !j.havePTS {
+				j.spanStart, j.lastPTS, j.havePTS = v, v, true
+			} else if ptsDiff(v, j.lastPTS) > 0 {
+				j.lastPTS = v
+			}
+		}
+
+		b := pkt.Bytes()
+		if _, ok := shift[pid]; !ok {
+			shift[pid] = 0
+			if last, ok := j.cc[pid]; ok {
+				next := last
+				if hasPayload(pkt) {
+					next = (last + 1) & 0x0F
+				}
+				shift[pid] = next - continuityCounter(pkt)
+				if unaligned {
+					if err := j.writeDiscontinuity(pid); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		cc := (continuityCounter(pkt) + shift[pid]) & 0x0F
+		b[3] = b[3]&0xF0 | byte(cc)
+		j.cc[pid] = cc
+
+		if _, err := j.w.Write(b); err != nil {
+			return err
+		}
+		j.result.Packets++
+	}
+
+	return nil
+}
+
+// JoinFiles joins recordings of one airing that was split over several files,
+// given in time order. The start of each part that overlaps the part before
+// it is dropped, parts resume at the first random access point after the
+// previous part ended. Timestamps are kept, so gaps between the parts remain,
+// and parts that don't share a clock with the part before them are marked
+// with a discontinuity. Continuity counters run on across the joins.
+func JoinFiles(output string, inputs []string) (*JoinResult, error) {
+	out, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &joiner{w: bufio.NewWriter(out), cc: map[int16]int{}}
+	for i, input := range inputs {
+		if err = j.append(input, i == 0); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = j.w.Flush()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(output)
+		return nil, err
+	}
+
+	return &j.result, nil
+}
@@ -0,0 +1,126 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testPart is a recording with a video frame every second from first to
+// last, with random access points where ra says so.
+type testPart struct {
+	first, last int64
+	ra          func(second int64) bool
+}
+
+func (p testPart) stream() []byte {
+	data := append(patPacket(0), pmtPacket(0, testVideoPid, testStreams)...)
+	cc := 0
+	for s := p.first; s <= p.last; s++ {
+		var af []byte
+		if p.ra == nil || p.ra(s) {
+			af = []byte{0x40}
+		}
+		data = append(data, pesPackets(testVideoPid, &cc, af, pesPacket(0xE0, (s*ptsClock)%ptsWrap, []byte{0, 0, 1, 0xB3}))...)
+	}
+	return data
+}
+
+func TestJoinFiles(t *testing.T) {
+	even := func(s int64) bool { return s%2 == 0 }
+	never := func(s int64) bool { return false }
+
+	tests := []struct {
+		name     string
+		parts    []testPart
+		result   JoinResult
+		duration time.Duration
+	}{
+		{"overlap", []testPart{{0, 10, nil}, {8, 20, nil}},
+			JoinResult{Packets: 2 + 11 + 10, Skipped: 2 + 3}, 20 * time.Second},
+		{"overlap to a random access point", []testPart{{0, 10, nil}, {8, 20, even}},
+			JoinResult{Packets: 2 + 11 + 9, Skipped: 2 + 4, Gaps: []Gap{{10 * time.Second, 2 * time.Second}}}, 20 * time.Second},
+		{"no random access points", []testPart{{0, 10, nil}, {8, 20, never}},
+			JoinResult{Packets: 2 + 11 + 8, Skipped: 2 + 5, Gaps: []Gap{{10 * time.Second, 3 * time.Second}}}, 20 * time.Second},
+		{"gap", []testPart{{0, 10, nil}, {15, 20, nil}, {21, 30, nil}},
+			JoinResult{Packets: 2 + 11 + 6 + 10, Skipped: 2 + 2, Gaps: []Gap{{10 * time.Second, 5 * time.Second}}}, 30 * time.Second},
+		// The unaligned part starts with a discontinuity on the video PID.
+		{"unaligned", []testPart{{0, 10, nil}, {90000, 90005, nil}},
+			JoinResult{Packets: 2 + 11 + 1 + 6, Skipped: 2, Unaligned: 1}, 15 * time.Second},
+		{"gap after unaligned", []testPart{{0, 10, nil}, {90000, 90005, nil}, {90008, 90010, nil}},
+			JoinResult{Packets: 2 + 11 + 1 + 6 + 3, Skipped: 2 + 2, Gaps: []Gap{{15 * time.Second, 3 * time.Second}}, Unaligned: 1}, 20 * time.Second},
+		{"across the wrap", []testPart{{ptsWrap/ptsClock - 5, ptsWrap/ptsClock - 1, nil}, {ptsWrap / ptsClock, ptsWrap/ptsClock + 4, nil}},
+			JoinResult{Packets: 2 + 5 + 5, Skipped: 2}, 9 * time.Second},
+	}
+
+	dir, err := ioutil.TempDir("", "join")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range tests {
+		var inputs []string
+		for i, p := range tt.parts {
+			name := filepath.Join(dir, string(rune('a'+i))+".ts")
+			if err := ioutil.WriteFile(name, p.stream(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			inputs = append(inputs, name)
+		}
+
+		output := filepath.Join(dir, "joined.ts")
+		result, err := JoinFiles(output, inputs)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*result, tt.result) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *result, tt.result)
+		}
+		if info, err := os.Stat(output); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if info.Size() != result.Packets*188 {
+			t.Errorf("%s: output is %d bytes, want %d", tt.name, info.Size(), result.Packets*188)
+		}
+
+		// The analyzer sees one continuous stream, with the gaps in its
+		// timeline.
+		report, err := AnalyzeFile(output)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if report.ContinuityErrors != 0 {
+			t.Errorf("%s: %d continuity errors in the joined stream", tt.name, report.ContinuityErrors)
+		}
+		if report.Duration != tt.duration {
+			t.Errorf("%s: joined stream is %v, want %v", tt.name, report.Duration, tt.duration)
+		}
+	}
+
+	if _, err := JoinFiles(filepath.Join(dir, "missing.ts"), []string{filepath.Join(dir, "nothing.ts")}); err == nil {
+		t.Errorf("JoinFiles of a missing file succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.ts")); err == nil {
+		t.Errorf("JoinFiles left its output behind after failing")
+	}
+}
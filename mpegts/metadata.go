@@ -0,0 +1,270 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+var (
+	ErrNoMetadata      = errors.New("No HDHomeRun metadata found")
+	ErrDiscontinuity   = errors.New("Discontinuity in HDHomeRun metadata")
+	ErrInvalidMetadata = errors.New("Invalid HDHomeRun metadata")
+)
+
+func ReadMetadataFile(filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadMetadata(file)
+}
+
+// ReadMetadata reads the JSON metadata the HDHomeRun record engine stores in
+// PID 0x1FFA packets at the start of a recording.
+func ReadMetadata(r io.Reader) ([]byte, error) {
+	var (
+		buf     [ts.PktLen]byte
+		data    []byte
+		started bool
+		lastCC  int
+	)
+
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+
+	for {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+
+		if pkt.Pid() != PidHDHRMeta {
+			break
+		}
+
+		if !started {
+			if !payloadUnitStart(pkt) {
+				continue
+			}
+			started = true
+		} else if payloadUnitStart(pkt) {
+			break
+		} else if hasPayload(pkt) && continuityCounter(pkt) != (lastCC+1)&0x0F {
+			return nil, ErrDiscontinuity
+		}
+		lastCC = continuityCounter(pkt)
+
+		data = append(data, payload(pkt)...)
+	}
+
+	if !started {
+		return nil, ErrNoMetadata
+	}
+
+	data = stripPESHeader(data)
+	data = bytes.TrimRight(data, "\xFF")
+	data = bytes.TrimRight(data, "\x00")
+
+	if !json.Valid(data) {
+		return nil, ErrInvalidMetadata
+	}
+
+	return data, nil
+}
+
+// stripPESHeader removes the PES header from data if there is one. The record
+// engine writes bare JSON, but some tools wrap it in a private stream PES.
+func stripPESHeader(data []byte) []byte {
+	if len(data) < 9 || !bytes.HasPrefix(data, []byte{0x00, 0x00, 0x01}) {
+		return data
+	}
+
+	end := 9 + int(data[8])
+	if end > len(data) {
+		return nil
+	}
+
+	return data[end:]
+}
+
+// Number of packets the record engine reserves for metadata.
+const MetadataPackets = 64
+
+// EncodeMetadata packs jsonBuf into PID 0x1FFA packets, padded to at least
+// minPackets packets.
+func EncodeMetadata(jsonBuf []byte, minPackets int) []byte {
+	const payloadLen = ts.PktLen - 4
+
+	n := (len(jsonBuf) + payloadLen - 1) / payloadLen
+	if n < minPackets {
+		n = minPackets
+	}
+
+	out := bytes.Repeat([]byte{0xFF}, n*ts.PktLen)
+	for i := 0; i < n; i++ {
+		pkt := out[i*ts.PktLen : (i+1)*ts.PktLen]
+		pkt[0] = 0x47
+		pkt[1] = byte(PidHDHRMeta >> 8)
+		pkt[2] = byte(PidHDHRMeta & 0xFF)
+		pkt[3] = 0x10 | byte(i&0x0F)
+		if i == 0 {
+			pkt[1] |= 0x40
+		}
+
+		if off := i * payloadLen; off < len(jsonBuf) {
+			copy(pkt[4:], jsonBuf[off:])
+		}
+	}
+
+	return out
+}
+
+// countMetadataPackets returns the number of PID 0x1FFA packets at the start
+// of r.
+func countMetadataPackets(r io.Reader) (int, error) {
+	var buf [ts.PktLen]byte
+	n := 0
+
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+	for {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return n, nil
+			}
+			return n, err
+		}
+		if pkt.Pid() != PidHDHRMeta {
+			return n, nil
+		}
+		n++
+	}
+}
+
+// WriteMetadataFile replaces the metadata of an existing recording. The
+// header is overwritten in place when the new metadata fits, otherwise the
+// recording is rewritten through a temporary file.
+func WriteMetadataFile(filename string, jsonBuf []byte) error {
+	if !json.Valid(jsonBuf) {
+		return ErrInvalidMetadata
+	}
+
+	file, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	n, err := countMetadataPackets(file)
+	if err != nil {
+		return err
+	}
+
+	header := EncodeMetadata(jsonBuf, n)
+	if len(header) == n*ts.PktLen {
+		if _, err = file.WriteAt(header, 0); err != nil {
+			return err
+		}
+		return file.Sync()
+	}
+
+	header = EncodeMetadata(jsonBuf, MetadataPackets)
+
+	return rewriteHeader(file, filename, header, int64(n*ts.PktLen))
+}
+
+// rewriteHeader copies file to a temporary file with its first skip bytes
+// replaced by header, then renames it over filename.
+func rewriteHeader(file *os.File, filename string, header []byte, skip int64) error {
+	if _, err := file.Seek(skip, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(header); err == nil {
+		if _, err = io.Copy(tmp, file); err == nil {
+			err = tmp.Sync()
+		}
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if info, err := file.Stat(); err == nil {
+		os.Chmod(tmp.Name(), info.Mode())
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// CopyWithMetadata writes a recording to w made of the metadata in jsonBuf
+// followed by the packets from r. Any metadata packets already in r are
+// dropped.
+func CopyWithMetadata(w io.Writer, r io.Reader, jsonBuf []byte) error {
+	if !json.Valid(jsonBuf) {
+		return ErrInvalidMetadata
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(EncodeMetadata(jsonBuf, MetadataPackets)); err != nil {
+		return err
+	}
+
+	var buf [ts.PktLen]byte
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+	for {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				continue
+			}
+			return err
+		}
+		if pkt.Pid() == PidHDHRMeta {
+			continue
+		}
+		if _, err := bw.Write(pkt.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
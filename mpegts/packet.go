@@ -0,0 +1,131 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"time"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+const (
+	PidPAT      int16 = 0x0000
+	PidHDHRMeta int16 = 0x1FFA
+	PidNull     int16 = 0x1FFF
+
+	pcrClock = 27000000
+	pcrWrap  = (1 << 33) * 300
+)
+
+// The accessors below work directly on the packet bytes so that they can be
+// used on packets from any ts.Pkt implementation.
+
+func transportError(pkt ts.Pkt) bool {
+	return pkt.Bytes()[1]&0x80 != 0
+}
+
+func payloadUnitStart(pkt ts.Pkt) bool {
+	return pkt.Bytes()[1]&0x40 != 0
+}
+
+func continuityCounter(pkt ts.Pkt) int {
+	return int(pkt.Bytes()[3] & 0x0F)
+}
+
+func hasAdaptationField(pkt ts.Pkt) bool {
+	b := pkt.Bytes()
+	return b[3]&0x20 != 0 && b[4] > 0
+}
+
+func hasPayload(pkt ts.Pkt) bool {
+	return pkt.Bytes()[3]&0x10 != 0
+}
+
+func discontinuity(pkt ts.Pkt) bool {
+	return hasAdaptationField(pkt) && pkt.Bytes()[5]&0x80 != 0
+}
+
+func randomAccess(pkt ts.Pkt) bool {
+	return hasAdaptationField(pkt) && pkt.Bytes()[5]&0x40 != 0
+}
+
+// pcr returns the program clock reference of pkt in 27MHz units.
+func pcr(pkt ts.Pkt) (int64, bool) {
+	b := pkt.Bytes()
+	if !hasAdaptationField(pkt) || b[4] < 7 || b[5]&0x10 == 0 {
+		return 0, false
+	}
+
+	base := int64(b[6])<<25 | int64(b[7])<<17 | int64(b[8])<<9 | int64(b[9])<<1 | int64(b[10])>>7
+	ext := int64(b[10]&0x01)<<8 | int64(b[11])
+
+	return base*300 + ext, true
+}
+
+// payload returns the payload of pkt, skipping any adaptation field.
+func payload(pkt ts.Pkt) []byte {
+	b := pkt.Bytes()
+	if !hasPayload(pkt) {
+		return nil
+	}
+
+	offset := 4
+	if b[3]&0x20 != 0 {
+		offset += 1 + int(b[4])
+	}
+	if offset >= ts.PktLen {
+		return nil
+	}
+
+	return b[offset:]
+}
+
+const (
+	ptsClock = 90000
+	ptsWrap  = 1 << 33
+)
+
+// pts returns the presentation timestamp of the PES packet starting in pkt.
+func pts(pkt ts.Pkt) (int64, bool) {
+	if !payloadUnitStart(pkt) {
+		return 0, false
+	}
+
+	p := payload(pkt)
+	if len(p) < 14 || p[0] != 0x00 || p[1] != 0x00 || p[2] != 0x01 || p[7]&0x80 == 0 {
+		return 0, false
+	}
+
+	return parseTimestamp(p[9:14]), true
+}
+
+// parseTimestamp decodes a 33 bit PES timestamp.
+func parseTimestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 | int64(b[1])<<22 | int64(b[2]>>1)<<15 | int64(b[3])<<7 | int64(b[4]>>1)
+}
+
+// ptsDiff returns a - b, allowing for a wrap between them.
+func ptsDiff(a, b int64) int64 {
+	d := (a - b) & (ptsWrap - 1)
+	if d >= ptsWrap/2 {
+		d -= ptsWrap
+	}
+	return d
+}
+
+func ptsDuration(ticks int64) time.Duration {
+	return time.Duration(ticks/ptsClock)*time.Second + time.Duration(ticks%ptsClock)*time.Second/ptsClock
+}
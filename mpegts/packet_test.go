@@ -0,0 +1,244 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+// tsPacket builds a packet on pid carrying data. The adaptation field holds
+// af, which starts with the flags byte, and stuffing to fill the packet.
+func tsPacket(pid int16, pusi bool, cc int, af, data []byte) []byte {
+	pkt := []byte{0x47, byte(pid>>8) & 0x1F, byte(pid), byte(cc & 0x0F)}
+	if pusi {
+		pkt[1] |= 0x40
+	}
+	if len(data) > 0 {
+		pkt[3] |= 0x10
+	}
+
+	stuffing := ts.PktLen - 4 - len(data)
+	if af != nil || stuffing > 0 {
+		pkt[3] |= 0x20
+		n := stuffing - 1
+		if n < len(af) {
+			panic("packet too long")
+		}
+		pkt = append(pkt, byte(n))
+		if n > 0 {
+			field := make([]byte, n)
+			for i := range field {
+				field[i] = 0xFF
+			}
+			field[0] = 0
+			copy(field, af)
+			pkt = append(pkt, field...)
+		}
+	}
+
+	return append(pkt, data...)
+}
+
+// pcrField returns an adaptation field carrying pcr.
+func pcrField(pcr int64, flags byte) []byte {
+	base, ext := pcr/300, pcr%300
+	return []byte{
+		0x10 | flags,
+		byte(base >> 25), byte(base >> 17), byte(base >> 9), byte(base >> 1),
+		byte(base&1)<<7 | 0x7E | byte(ext>>8), byte(ext),
+	}
+}
+
+func encodeTimestamp(prefix byte, v int64) []byte {
+	return []byte{
+		prefix<<4 | byte(v>>29)&0x0E | 1,
+		byte(v >> 22),
+		byte(v>>14)&0xFE | 1,
+		byte(v >> 7),
+		byte(v<<1)&0xFE | 1,
+	}
+}
+
+// pesPacket returns a PES packet with a PTS.
+func pesPacket(streamID byte, pts int64, es []byte) []byte {
+	pes := []byte{0x00, 0x00, 0x01, streamID, 0, 0, 0x80, 0x80, 5}
+	pes = append(pes, encodeTimestamp(0x2, pts)...)
+	if streamID < 0xE0 || streamID > 0xEF {
+		length := len(pes) - 6 + len(es)
+		pes[4], pes[5] = byte(length>>8), byte(length)
+	}
+	return append(pes, es...)
+}
+
+// pesPackets splits pes into packets on pid, counting cc. af is put in the
+// first packet.
+func pesPackets(pid int16, cc *int, af, pes []byte) []byte {
+	var out []byte
+
+	first := true
+	for len(pes) > 0 {
+		room := ts.PktLen - 4
+		if first && af != nil {
+			room -= 1 + len(af)
+		}
+		n := len(pes)
+		if n > room {
+			n = room
+		}
+
+		var field []byte
+		if first {
+			field = af
+		}
+		out = append(out, tsPacket(pid, first, *cc, field, pes[:n])...)
+		*cc = (*cc + 1) & 0x0F
+		pes = pes[n:]
+		first = false
+	}
+
+	return out
+}
+
+// sectionPacket returns a packet carrying a single section.
+func sectionPacket(pid int16, cc int, section []byte) []byte {
+	data := append([]byte{0}, section...)
+	for len(data) < ts.PktLen-4 {
+		data = append(data, 0xFF)
+	}
+	return tsPacket(pid, true, cc, nil, data)
+}
+
+// testStream describes a program for building test transport streams.
+type testStream struct {
+	typ         StreamType
+	pid         int16
+	descriptors []byte
+}
+
+const testPMTPid = 0x100
+
+func patPacket(cc int) []byte {
+	return sectionPacket(PidPAT, cc, buildSection(tablePAT, 1, 0, []byte{0x00, 0x01, 0xE0 | testPMTPid>>8, testPMTPid & 0xFF}))
+}
+
+func pmtPacket(cc int, pcrPid int16, streams []testStream) []byte {
+	body := []byte{0xE0 | byte(pcrPid>>8), byte(pcrPid), 0xF0, 0x00}
+	for _, s := range streams {
+		body = append(body, byte(s.typ), 0xE0|byte(s.pid>>8), byte(s.pid), 0xF0|byte(len(s.descriptors)>>8), byte(len(s.descriptors)))
+		body = append(body, s.descriptors...)
+	}
+	return sectionPacket(testPMTPid, cc, buildSection(tablePMT, 1, 0, body))
+}
+
+// packets splits a transport stream into its packets.
+func packets(data []byte) []ts.Pkt {
+	var pkts []ts.Pkt
+	for ; len(data) >= ts.PktLen; data = data[ts.PktLen:] {
+		pkts = append(pkts, ts.AsPkt(data[:ts.PktLen]))
+	}
+	return pkts
+}
+
+func TestPacketAccessors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkt     []byte
+		pcr     int64
+		hasPCR  bool
+		pts     int64
+		hasPTS  bool
+		disc    bool
+		random  bool
+		payload int
+	}{
+		{
+			name:    "payload only",
+			pkt:     tsPacket(0x100, false, 3, nil, bytes.Repeat([]byte{0xAA}, 184)),
+			payload: 184,
+		},
+		{
+			name:    "stuffed payload",
+			pkt:     tsPacket(0x100, false, 3, nil, []byte{1, 2, 3}),
+			payload: 3,
+		},
+		{
+			name:    "pcr",
+			pkt:     tsPacket(0x100, false, 0, pcrField(12345678901, 0x40), []byte{1}),
+			pcr:     12345678901,
+			hasPCR:  true,
+			random:  true,
+			payload: 1,
+		},
+		{
+			name:    "discontinuity",
+			pkt:     tsPacket(0x100, false, 0, pcrField(pcrWrap-1, 0x80), nil),
+			pcr:     pcrWrap - 1,
+			hasPCR:  true,
+			disc:    true,
+			payload: 0,
+		},
+		{
+			name:    "pts",
+			pkt:     tsPacket(0x100, true, 0, nil, pesPacket(0xE0, ptsWrap-1, []byte{0, 0, 1, 0xB3})),
+			pts:     ptsWrap - 1,
+			hasPTS:  true,
+			payload: 18,
+		},
+	}
+
+	for _, tt := range tests {
+		if len(tt.pkt) != ts.PktLen {
+			t.Fatalf("%s: packet is %d bytes", tt.name, len(tt.pkt))
+		}
+		pkt := ts.AsPkt(tt.pkt)
+
+		if v, ok := pcr(pkt); ok != tt.hasPCR || v != tt.pcr {
+			t.Errorf("%s: pcr = %d %v, want %d %v", tt.name, v, ok, tt.pcr, tt.hasPCR)
+		}
+		if v, ok := pts(pkt); ok != tt.hasPTS || v != tt.pts {
+			t.Errorf("%s: pts = %d %v, want %d %v", tt.name, v, ok, tt.pts, tt.hasPTS)
+		}
+		if discontinuity(pkt) != tt.disc {
+			t.Errorf("%s: discontinuity = %v", tt.name, discontinuity(pkt))
+		}
+		if randomAccess(pkt) != tt.random {
+			t.Errorf("%s: randomAccess = %v", tt.name, randomAccess(pkt))
+		}
+		if n := len(payload(pkt)); n != tt.payload {
+			t.Errorf("%s: payload is %d bytes, want %d", tt.name, n, tt.payload)
+		}
+	}
+}
+
+func TestPTSDiff(t *testing.T) {
+	tests := []struct {
+		a, b, want int64
+	}{
+		{100, 50, 50},
+		{50, 100, -50},
+		{10, ptsWrap - 10, 20},
+		{ptsWrap - 10, 10, -20},
+	}
+
+	for _, tt := range tests {
+		if got := ptsDiff(tt.a, tt.b); got != tt.want {
+			t.Errorf("ptsDiff(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+// Give up looking for the PMTs after this many packets.
+const maxPSIPackets = 50000
+
+const (
+	tablePAT = 0x00
+	tablePMT = 0x02
+)
+
+type StreamType uint8
+
+const (
+	StreamMPEG1Video StreamType = 0x01
+	StreamMPEG2Video StreamType = 0x02
+	StreamMPEG1Audio StreamType = 0x03
+	StreamMPEG2Audio StreamType = 0x04
+	StreamSections   StreamType = 0x05
+	StreamPrivate    StreamType = 0x06
+	StreamDSMCC      StreamType = 0x0B
+	StreamAAC        StreamType = 0x0F
+	StreamAACLATM    StreamType = 0x11
+	StreamMetadata   StreamType = 0x15
+	StreamH264       StreamType = 0x1B
+	StreamHEVC       StreamType = 0x24
+	StreamMPEGH      StreamType = 0x2D
+	StreamSCTE35     StreamType = 0x86
+	StreamAC3        StreamType = 0x81
+	StreamEAC3       StreamType = 0x87
+	StreamATSCData   StreamType = 0x95
+)
+
+var streamTypeNames = map[StreamType]string{
+	StreamMPEG1Video: "MPEG-1 video",
+	StreamMPEG2Video: "MPEG-2 video",
+	StreamMPEG1Audio: "MPEG-1 audio",
+	StreamMPEG2Audio: "MPEG-2 audio",
+	StreamSections:   "private sections",
+	StreamPrivate:    "private data",
+	StreamDSMCC:      "DSM-CC",
+	StreamAAC:        "AAC",
+	StreamAACLATM:    "AAC LATM",
+	StreamMetadata:   "metadata",
+	StreamH264:       "H.264",
+	StreamHEVC:       "HEVC",
+	StreamMPEGH:      "MPEG-H 3D Audio",
+	StreamSCTE35:     "SCTE-35",
+	StreamAC3:        "AC-3",
+	StreamEAC3:       "E-AC-3",
+	StreamATSCData:   "ATSC data",
+}
+
+func (t StreamType) String() string {
+	if name, ok := streamTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", uint8(t))
+}
+
+func (t StreamType) IsVideo() bool {
+	switch t {
+	case StreamMPEG1Video, StreamMPEG2Video, StreamH264, StreamHEVC:
+		return true
+	}
+	return false
+}
+
+func (t StreamType) IsAudio() bool {
+	switch t {
+	case StreamMPEG1Audio, StreamMPEG2Audio, StreamAAC, StreamAACLATM, StreamMPEGH, StreamAC3, StreamEAC3:
+		return true
+	}
+	return false
+}
+
+// Descriptor tags we interpret.
+const (
+	descRegistration   = 0x05
+	descISO639Language = 0x0A
+	descAC3            = 0x6A
+	descEAC3           = 0x7A
+	descCaptionService = 0x86
+	descExtension      = 0x7F
+)
+
+// DVB extension descriptor tags.
+const (
+	descExtAC4 = 0x15
+)
+
+type Descriptor struct {
+	Tag  uint8
+	Data []byte
+}
+
+func parseDescriptors(data []byte) []Descriptor {
+	var descriptors []Descriptor
+
+	for len(data) >= 2 {
+		length := int(data[1])
+		if len(data) < 2+length {
+			break
+		}
+		descriptors = append(descriptors, Descriptor{Tag: data[0], Data: data[2 : 2+length]})
+		data = data[2+length:]
+	}
+
+	return descriptors
+}
+
+type CaptionService struct {
+	Language string
+	Digital  bool
+	Service  int
+	EasyRead bool
+	Wide     bool
+}
+
+type Stream struct {
+	Pid         int16
+	Type        StreamType
+	Codec       string
+	Language    string
+	AudioType   uint8
+	Captions    []CaptionService
+	Descriptors []Descriptor
+}
+
+func (s *Stream) IsVideo() bool {
+	return s.Type.IsVideo()
+}
+
+func (s *Stream) IsAudio() bool {
+	return s.Type.IsAudio() || s.Codec == "AC-3" || s.Codec == "E-AC-3" || s.Codec == "AC-4"
+}
+
+// IsPES reports whether the stream is carried in PES packets rather than
+// sections, such as SCTE-35.
+func (s *Stream) IsPES() bool {
+	return s.IsVideo() || s.IsAudio() || s.Type == StreamPrivate
+}
+
+func (s *Stream) parseDescriptors() {
+	s.Codec = s.Type.String()
+
+	for _, d := range s.Descriptors {
+		switch d.Tag {
+		case descISO639Language:
+			if len(d.Data) >= 4 {
+				s.Language = string(d.Data[0:3])
+				s.AudioType = d.Data[3]
+			}
+		case descAC3:
+			if s.Type == StreamPrivate {
+				s.Codec = "AC-3"
+			}
+		case descEAC3:
+			if s.Type == StreamPrivate {
+				s.Codec = "E-AC-3"
+			}
+		case descRegistration:
+			if len(d.Data) >= 4 && s.Type == StreamPrivate {
+				switch string(d.Data[0:4]) {
+				case "AC-3":
+					s.Codec = "AC-3"
+				case "EAC3":
+					s.Codec = "E-AC-3"
+				case "AC-4":
+					s.Codec = "AC-4"
+				}
+			}
+		case descExtension:
+			if len(d.Data) >= 1 && d.Data[0] == descExtAC4 && s.Type == StreamPrivate {
+				s.Codec = "AC-4"
+			}
+		case descCaptionService:
+			s.Captions = parseCaptionServices(d.Data)
+		}
+	}
+}
+
+// parseCaptionServices decodes an ATSC A/65 caption_service_descriptor.
+func parseCaptionServices(data []byte) []CaptionService {
+	var services []CaptionService
+
+	if len(data) < 1 {
+		return nil
+	}
+	n := int(data[0] & 0x1F)
+	data = data[1:]
+
+	for i := 0; i < n && len(data) >= 6; i++ {
+		c := CaptionService{
+			Language: string(data[0:3]),
+			Digital:  data[3]&0x80 != 0,
+		}
+		if c.Digital {
+			c.Service = int(data[3] & 0x3F)
+			c.EasyRead = data[4]&0x80 != 0
+			c.Wide = data[4]&0x40 != 0
+		} else {
+			c.Service = int(data[3]&0x01) + 1
+		}
+		services = append(services, c)
+		data = data[6:]
+	}
+
+	return services
+}
+
+type Program struct {
+	Number  uint16
+	PMTPid  int16
+	PCRPid  int16
+	Streams []*Stream
+}
+
+func parsePAT(s Section) map[uint16]int16 {
+	programs := map[uint16]int16{}
+
+	data := s.Data()
+	for len(data) >= 4 {
+		number := uint16(data[0])<<8 | uint16(data[1])
+		pid := int16(data[2]&0x1F)<<8 | int16(data[3])
+		if number != 0 {
+			programs[number] = pid
+		}
+		data = data[4:]
+	}
+
+	return programs
+}
+
+func parsePMT(s Section, pmtPid int16) *Program {
+	p := &Program{Number: s.TableIDExtension(), PMTPid: pmtPid}
+
+	data := s.Data()
+	if len(data) < 4 {
+		return p
+	}
+	p.PCRPid = int16(data[0]&0x1F)<<8 | int16(data[1])
+
+	infoLen := int(data[2]&0x0F)<<8 | int(data[3])
+	if 4+infoLen > len(data) {
+		return p
+	}
+	data = data[4+infoLen:]
+
+	for len(data) >= 5 {
+		esLen := int(data[3]&0x0F)<<8 | int(data[4])
+		if 5+esLen > len(data) {
+			break
+		}
+
+		stream := &Stream{
+			Type:        StreamType(data[0]),
+			Pid:         int16(data[1]&0x1F)<<8 | int16(data[2]),
+			Descriptors: parseDescriptors(data[5 : 5+esLen]),
+		}
+		stream.parseDescriptors()
+		p.Streams = append(p.Streams, stream)
+
+		data = data[5+esLen:]
+	}
+
+	return p
+}
+
+// programReader collects the PAT and PMTs from a stream of packets.
+type programReader struct {
+	readers  map[int16]*sectionReader
+	pmtPids  map[int16]uint16
+	programs map[uint16]*Program
+	havePAT  bool
+}
+
+func newProgramReader() *programReader {
+	return &programReader{
+		readers:  map[int16]*sectionReader{PidPAT: {}},
+		pmtPids:  map[int16]uint16{},
+		programs: map[uint16]*Program{},
+	}
+}
+
+func (p *programReader) done() bool {
+	return p.havePAT && len(p.programs) == len(p.pmtPids)
+}
+
+func (p *programReader) push(pkt ts.Pkt) {
+	pid := pkt.Pid()
+	reader, ok := p.readers[pid]
+	if !ok || transportError(pkt) {
+		return
+	}
+
+	sections, _ := reader.push(pkt)
+	for _, s := range sections {
+		switch {
+		case pid == PidPAT && s.TableID() == tablePAT && !p.havePAT:
+			p.havePAT = true
+			for number, pmtPid := range parsePAT(s) {
+				p.pmtPids[pmtPid] = number
+				p.readers[pmtPid] = &sectionReader{}
+			}
+		case s.TableID() == tablePMT:
+			if _, ok := p.pmtPids[pid]; ok {
+				p.programs[s.TableIDExtension()] = parsePMT(s, pid)
+			}
+		}
+	}
+}
+
+func (p *programReader) list() []*Program {
+	var programs []*Program
+	for _, prog := range p.programs {
+		programs = append(programs, prog)
+	}
+	sort.Slice(programs, func(i, j int) bool {
+		return programs[i].Number < programs[j].Number
+	})
+
+	return programs
+}
+
+func ReadProgramsFile(filename string) ([]*Program, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadPrograms(file)
+}
+
+// ReadPrograms reads the PAT and PMTs at the start of a transport stream.
+func ReadPrograms(r io.Reader) ([]*Program, error) {
+	var buf [ts.PktLen]byte
+
+	p := newProgramReader()
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+
+	for i := 0; i < maxPSIPackets && !p.done(); i++ {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				continue
+			}
+			return nil, err
+		}
+		p.push(pkt)
+	}
+
+	if !p.havePAT {
+		return nil, ErrNoPAT
+	}
+
+	return p.list(), nil
+}
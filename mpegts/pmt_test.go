@@ -0,0 +1,93 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReadPrograms(t *testing.T) {
+	lang := func(code string, audioType byte) []byte {
+		return append([]byte{descISO639Language, 4}, append([]byte(code), audioType)...)
+	}
+	captions := []byte{descCaptionService, 13, 0xC2,
+		'e', 'n', 'g', 0x81, 0x40, 0x00,
+		's', 'p', 'a', 0x3F, 0x00, 0x00,
+	}
+
+	tests := []struct {
+		stream    testStream
+		codec     string
+		language  string
+		audioType uint8
+		audio     bool
+		captions  []CaptionService
+	}{
+		{stream: testStream{StreamH264, 0x31, captions}, codec: "H.264", captions: []CaptionService{
+			{Language: "eng", Digital: true, Service: 1, Wide: true},
+			{Language: "spa", Service: 2},
+		}},
+		{stream: testStream{StreamAC3, 0x34, lang("eng", 0)}, codec: "AC-3", language: "eng", audio: true},
+		{stream: testStream{StreamAC3, 0x35, lang("spa", 3)}, codec: "AC-3", language: "spa", audioType: 3, audio: true},
+		{stream: testStream{StreamPrivate, 0x36, []byte{descAC3, 1, 0}}, codec: "AC-3", audio: true},
+		{stream: testStream{StreamPrivate, 0x37, []byte{descEAC3, 1, 0}}, codec: "E-AC-3", audio: true},
+		{stream: testStream{StreamPrivate, 0x38, []byte{descRegistration, 4, 'A', 'C', '-', '4'}}, codec: "AC-4", audio: true},
+		{stream: testStream{StreamPrivate, 0x39, []byte{descExtension, 1, descExtAC4}}, codec: "AC-4", audio: true},
+		{stream: testStream{StreamPrivate, 0x3A, nil}, codec: "private data"},
+		{stream: testStream{StreamMPEGH, 0x3B, nil}, codec: "MPEG-H 3D Audio", audio: true},
+		{stream: testStream{StreamSCTE35, 0x3C, nil}, codec: "SCTE-35"},
+		{stream: testStream{StreamType(0x99), 0x3D, nil}, codec: "0x99"},
+		// Truncated descriptors are ignored.
+		{stream: testStream{StreamAAC, 0x3E, []byte{descISO639Language, 2, 'e', 'n'}}, codec: "AAC", audio: true},
+	}
+
+	var streams []testStream
+	for _, tt := range tests {
+		streams = append(streams, tt.stream)
+	}
+	programs, err := ReadPrograms(bytes.NewReader(append(patPacket(0), pmtPacket(0, 0x31, streams)...)))
+	if err != nil {
+		t.Fatalf("ReadPrograms: %v", err)
+	}
+	if len(programs) != 1 || programs[0].Number != 1 || programs[0].PMTPid != testPMTPid || programs[0].PCRPid != 0x31 {
+		t.Fatalf("ReadPrograms = %+v", programs)
+	}
+	if len(programs[0].Streams) != len(tests) {
+		t.Fatalf("ReadPrograms found %d streams, want %d", len(programs[0].Streams), len(tests))
+	}
+
+	for i, tt := range tests {
+		s := programs[0].Streams[i]
+		if s.Pid != tt.stream.pid || s.Type != tt.stream.typ {
+			t.Errorf("stream %d is 0x%04X %v, want 0x%04X %v", i, s.Pid, s.Type, tt.stream.pid, tt.stream.typ)
+		}
+		if s.Codec != tt.codec || s.Language != tt.language || s.AudioType != tt.audioType || s.IsAudio() != tt.audio {
+			t.Errorf("PID 0x%04X: got %q %q %d audio %v, want %q %q %d audio %v", s.Pid,
+				s.Codec, s.Language, s.AudioType, s.IsAudio(), tt.codec, tt.language, tt.audioType, tt.audio)
+		}
+		if !reflect.DeepEqual(s.Captions, tt.captions) {
+			t.Errorf("PID 0x%04X: captions %+v, want %+v", s.Pid, s.Captions, tt.captions)
+		}
+	}
+}
+
+func TestReadProgramsNoPAT(t *testing.T) {
+	if _, err := ReadPrograms(bytes.NewReader(pmtPacket(0, 0x31, nil))); err != ErrNoPAT {
+		t.Errorf("ReadPrograms without a PAT = %v, want %v", err, ErrNoPAT)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"errors"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+var (
+	ErrCRC   = errors.New("Section CRC mismatch")
+	ErrNoPAT = errors.New("No PAT found")
+)
+
+var crcTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+		table[i] = c
+	}
+	return table
+}()
+
+// crc32 computes the MPEG-2 CRC used by PSI sections.
+func crc32(data []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range data {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// Section is a complete PSI section including its header and CRC.
+type Section []byte
+
+func (s Section) TableID() uint8 {
+	return s[0]
+}
+
+// TableIDExtension returns the program number of a PMT, the transport stream
+// id of a PAT, and so on.
+func (s Section) TableIDExtension() uint16 {
+	return uint16(s[3])<<8 | uint16(s[4])
+}
+
+func (s Section) Version() uint8 {
+	return s[5] >> 1 & 0x1F
+}
+
+func (s Section) SectionNumber() uint8 {
+	return s[6]
+}
+
+// Data returns the section body after the long header and before the CRC.
+func (s Section) Data() []byte {
+	if s[1]&0x80 == 0 {
+		return s[3:]
+	}
+	return s[8 : len(s)-4]
+}
+
+// sectionReader reassembles the sections carried on a single PID.
+type sectionReader struct {
+	buf     []byte
+	started bool
+	lastCC  int
+}
+
+// push adds the payload of pkt and returns the sections completed by it.
+func (r *sectionReader) push(pkt ts.Pkt) ([]Section, error) {
+	data := payload(pkt)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	cc := continuityCounter(pkt)
+	if r.started && cc != (r.lastCC+1)&0x0F && !payloadUnitStart(pkt) {
+		r.buf, r.started = nil, false
+	}
+	r.lastCC = cc
+
+	if payloadUnitStart(pkt) {
+		pointer := int(data[0])
+		data = data[1:]
+		if pointer > len(data) {
+			r.buf, r.started = nil, false
+			return nil, nil
+		}
+		if r.started {
+			r.buf = append(r.buf, data[:pointer]...)
+		}
+		sections, err := r.sections()
+		r.buf = append(r.buf[:0], data[pointer:]...)
+		r.started = true
+		more, merr := r.sections()
+		if err == nil {
+			err = merr
+		}
+		return append(sections, more...), err
+	}
+
+	if !r.started {
+		return nil, nil
+	}
+	r.buf = append(r.buf, data...)
+
+	return r.sections()
+}
+
+// sections removes the complete sections from the start of the buffer.
+func (r *sectionReader) sections() ([]Section, error) {
+	var (
+		sections []Section
+		err      error
+	)
+
+	for len(r.buf) >= 3 && r.buf[0] != 0xFF {
+		length := 3 + (int(r.buf[1]&0x0F)<<8 | int(r.buf[2]))
+		if len(r.buf) < length {
+			break
+		}
+
+		s := Section(append([]byte(nil), r.buf[:length]...))
+		r.buf = r.buf[length:]
+
+		if s[1]&0x80 != 0 {
+			if length < 12 {
+				continue
+			}
+			if crc32(s) != 0 {
+				err = ErrCRC
+				continue
+			}
+		}
+		sections = append(sections, s)
+	}
+
+	if len(r.buf) > 0 && r.buf[0] == 0xFF {
+		r.buf = r.buf[:0]
+	}
+
+	return sections, err
+}
@@ -0,0 +1,107 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"testing"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+func TestCRC32(t *testing.T) {
+	if got := crc32([]byte("123456789")); got != 0x0376E6E7 {
+		t.Errorf("crc32 = 0x%08X, want 0x0376E6E7", got)
+	}
+	if s := buildSection(tablePAT, 1, 0, []byte{0, 1, 0xE1, 0}); crc32(s) != 0 {
+		t.Errorf("crc32 over a section and its CRC = 0x%08X, want 0", crc32(s))
+	}
+}
+
+// payloadPacket returns a packet carrying data, padded with 0xFF.
+func payloadPacket(pid int16, pusi bool, cc int, data []byte) []byte {
+	buf := make([]byte, ts.PktLen-4)
+	for i := range buf {
+		buf[i] = 0xFF
+	}
+	copy(buf, data)
+	return tsPacket(pid, pusi, cc, nil, buf)
+}
+
+func TestSectionReader(t *testing.T) {
+	pat := buildSection(tablePAT, 1, 0, []byte{0, 1, 0xE1, 0})
+	long := buildSection(tablePMT, 1, 0, make([]byte, 300))
+	bad := append([]byte(nil), pat...)
+	bad[len(bad)-1] ^= 0xFF
+
+	cat := func(parts ...[]byte) []byte {
+		var out []byte
+		for _, p := range parts {
+			out = append(out, p...)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		packets  [][]byte
+		sections []uint8
+		err      error
+	}{
+		{"single", [][]byte{payloadPacket(0x100, true, 0, cat([]byte{0}, pat))}, []uint8{tablePAT}, nil},
+		{"two in a packet", [][]byte{payloadPacket(0x100, true, 0, cat([]byte{0}, pat, long[:20]))}, []uint8{tablePAT}, nil},
+		{"spanning", [][]byte{
+			payloadPacket(0x100, true, 0, cat([]byte{0}, long[:183])),
+			payloadPacket(0x100, false, 1, long[183:]),
+		}, []uint8{tablePMT}, nil},
+		{"ended by pointer", [][]byte{
+			payloadPacket(0x100, true, 0, cat([]byte{0}, long[:183])),
+			payloadPacket(0x100, true, 1, cat([]byte{byte(len(long) - 183)}, long[183:], pat)),
+		}, []uint8{tablePMT, tablePAT}, nil},
+		{"lost packet", [][]byte{
+			payloadPacket(0x100, true, 0, cat([]byte{0}, long[:183])),
+			payloadPacket(0x100, false, 2, long[183:]),
+		}, nil, nil},
+		{"bad CRC", [][]byte{payloadPacket(0x100, true, 0, cat([]byte{0}, bad, pat))}, []uint8{tablePAT}, ErrCRC},
+		{"no start", [][]byte{payloadPacket(0x100, false, 0, long[183:])}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		r := &sectionReader{}
+		var (
+			got []uint8
+			err error
+		)
+		for _, pkt := range tt.packets {
+			sections, perr := r.push(ts.AsPkt(pkt))
+			if perr != nil {
+				err = perr
+			}
+			for _, s := range sections {
+				got = append(got, s.TableID())
+			}
+		}
+		if len(got) != len(tt.sections) || err != tt.err {
+			t.Errorf("%s: got %v %v, want %v %v", tt.name, got, err, tt.sections, tt.err)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.sections[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.sections)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+// StreamFilter selects the streams of a program to keep when remuxing.
+type StreamFilter func(p *Program, s *Stream) bool
+
+type RemuxResult struct {
+	Packets int64
+	Dropped int64
+	Streams []*Stream
+}
+
+type remuxer struct {
+	w        *bufio.Writer
+	keep     map[int16]bool
+	pmtPids  map[int16]map[int16]bool
+	programs map[uint16]bool
+	readers  map[int16]*sectionReader
+	cc       map[int16]byte
+	result   RemuxResult
+}
+
+func newRemuxer(w io.Writer, programs []*Program, filter StreamFilter) *remuxer {
+	m := &remuxer{
+		w:        bufio.NewWriter(w),
+		keep:     map[int16]bool{},
+		pmtPids:  map[int16]map[int16]bool{},
+		programs: map[uint16]bool{},
+		readers:  map[int16]*sectionReader{PidPAT: {}},
+		cc:       map[int16]byte{},
+	}
+
+	for _, p := range programs {
+		streams := map[int16]bool{}
+		for _, s := range p.Streams {
+			if s.Pid != PidHDHRMeta && filter(p, s) {
+				streams[s.Pid] = true
+				m.result.Streams = append(m.result.Streams, s)
+			}
+		}
+		if len(streams) == 0 {
+			continue
+		}
+
+		m.programs[p.Number] = true
+		m.pmtPids[p.PMTPid] = streams
+		m.readers[p.PMTPid] = &sectionReader{}
+		for pid := range streams {
+			m.keep[pid] = true
+		}
+		// The PCR may be carried on a PID of its own.
+		if p.PCRPid != PidNull {
+			m.keep[p.PCRPid] = true
+		}
+	}
+
+	return m
+}
+
+// nextCC returns the continuity counter for the next packet on pid.
+func (m *remuxer) nextCC(pid int16, payload bool) byte {
+	cc, ok := m.cc[pid]
+	if payload || !ok {
+		if ok {
+			cc = (cc + 1) & 0x0F
+		}
+		m.cc[pid] = cc
+	}
+	return cc
+}
+
+func (m *remuxer) write(pkt []byte) error {
+	m.result.Packets++
+	_, err := m.w.Write(pkt)
+	return err
+}
+
+// writeSection packetizes s on pid.
+func (m *remuxer) writeSection(pid int16, s []byte) error {
+	var pkt [ts.PktLen]byte
+
+	data := append([]byte{0}, s...)
+	for first := true; len(data) > 0; first = false {
+		for i := range pkt {
+			pkt[i] = 0xFF
+		}
+		pkt[0] = 0x47
+		pkt[1] = byte(pid>>8) & 0x1F
+		pkt[2] = byte(pid)
+		pkt[3] = 0x10 | m.nextCC(pid, true)
+		if first {
+			pkt[1] |= 0x40
+		}
+
+		n := copy(pkt[4:], data)
+		data = data[n:]
+
+		if err := m.write(pkt[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildSection assembles a long form section with a new CRC.
+func buildSection(tableID uint8, ext uint16, version uint8, body []byte) []byte {
+	length := 5 + len(body) + 4
+
+	s := []byte{
+		tableID, 0xB0 | byte(length>>8)&0x0F, byte(length),
+		byte(ext >> 8), byte(ext), 0xC1 | version<<1, 0, 0,
+	}
+	s = append(s, body...)
+
+	crc := crc32(s)
+	return append(s, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+// rewritePAT drops the programs without any kept streams.
+func (m *remuxer) rewritePAT(s Section) []byte {
+	var body []byte
+
+	data := s.Data()
+	for ; len(data) >= 4; data = data[4:] {
+		number := uint16(data[0])<<8 | uint16(data[1])
+		if m.programs[number] {
+			body = append(body, data[:4]...)
+		}
+	}
+
+	return buildSection(tablePAT, s.TableIDExtension(), s.Version(), body)
+}
+
+// rewritePMT drops the streams not in keep, leaving the descriptors of the
+// program and of the kept streams as they are.
+func rewritePMT(s Section, keep map[int16]bool) []byte {
+	data := s.Data()
+	if len(data) < 4 {
+		return s
+	}
+
+	infoLen := int(data[2]&0x0F)<<8 | int(data[3])
+	if 4+infoLen > len(data) {
+		return s
+	}
+	body := append([]byte(nil), data[:4+infoLen]...)
+	data = data[4+infoLen:]
+
+	for len(data) >= 5 {
+		esLen := int(data[3]&0x0F)<<8 | int(data[4])
+		if 5+esLen > len(data) {
+			break
+		}
+
+		pid := int16(data[1]&0x1F)<<8 | int16(data[2])
+		if keep[pid] {
+			body = append(body, data[:5+esLen]...)
+		}
+		data = data[5+esLen:]
+	}
+
+	return buildSection(tablePMT, s.TableIDExtension(), s.Version(), body)
+}
+
+func (m *remuxer) push(pkt ts.Pkt) error {
+	pid := pkt.Pid()
+
+	if reader, ok := m.readers[pid]; ok {
+		if transportError(pkt) {
+			return nil
+		}
+
+		sections, _ := reader.push(pkt)
+		for _, s := range sections {
+			var out []byte
+			switch {
+			case pid == PidPAT && s.TableID() == tablePAT:
+				out = m.rewritePAT(s)
+			case pid != PidPAT && s.TableID() == tablePMT && m.programs[s.TableIDExtension()]:
+				out = rewritePMT(s, m.pmtPids[pid])
+			default:
+				continue
+			}
+			if err := m.writeSection(pid, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !m.keep[pid] {
+		m.result.Dropped++
+		return nil
+	}
+
+	b := pkt.Bytes()
+	b[3] = b[3]&0xF0 | m.nextCC(pid, hasPayload(pkt))
+
+	return m.write(b)
+}
+
+func RemuxFile(input, output string, filter StreamFilter) (*RemuxResult, error) {
+	in, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	programs, err := ReadPrograms(in)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := Remux(out, in, programs, filter)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(output)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Remux copies the streams selected by filter from r to w. The HDHomeRun
+// metadata and all other PIDs are dropped, the PAT and PMTs are rewritten to
+// list only the kept streams and continuity counters are renumbered.
+func Remux(w io.Writer, r io.Reader, programs []*Program, filter StreamFilter) (*RemuxResult, error) {
+	var buf [ts.PktLen]byte
+
+	m := newRemuxer(w, programs, filter)
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+
+	for {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				continue
+			}
+			return nil, err
+		}
+		if err := m.push(pkt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return &m.result, nil
+}
@@ -0,0 +1,120 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRemux(t *testing.T) {
+	streams := []testStream{
+		testStreams[0],
+		testStreams[1],
+		{StreamAC3, testAudioPid + 1, []byte{descISO639Language, 4, 's', 'p', 'a', 0}},
+		testStreams[2],
+	}
+
+	var in []byte
+	in = append(in, patPacket(0)...)
+	in = append(in, pmtPacket(0, testVideoPid, streams)...)
+	cc := map[int16]int{}
+	for i := 0; i < 3; i++ {
+		for _, pid := range []int16{testVideoPid, testAudioPid, testAudioPid + 1, PidHDHRMeta, 0x1500} {
+			in = append(in, tsPacket(pid, false, cc[pid], nil, bytes.Repeat([]byte{0x55}, 184))...)
+			// Pretend a packet went missing, the output is renumbered.
+			cc[pid] += 2
+		}
+		in = append(in, scte35Packet(i)...)
+	}
+	programs, err := ReadPrograms(bytes.NewReader(in))
+	if err != nil {
+		t.Fatalf("ReadPrograms: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		filter  StreamFilter
+		pids    []int16
+		dropped int64
+	}{
+		{"everything", func(p *Program, s *Stream) bool { return true },
+			[]int16{testVideoPid, testAudioPid, testAudioPid + 1, testSCTE35Pid}, 6},
+		{"english audio", func(p *Program, s *Stream) bool { return !s.IsAudio() || s.Language == "eng" },
+			[]int16{testVideoPid, testAudioPid, testSCTE35Pid}, 9},
+		{"video only", func(p *Program, s *Stream) bool { return s.IsVideo() },
+			[]int16{testVideoPid}, 15},
+		// The PMT of a program without any kept streams is dropped too.
+		{"nothing", func(p *Program, s *Stream) bool { return false }, nil, 19},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		result, err := Remux(&out, bytes.NewReader(in), programs, tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		var kept []int16
+		for _, s := range result.Streams {
+			kept = append(kept, s.Pid)
+		}
+		if !reflect.DeepEqual(kept, tt.pids) || result.Dropped != tt.dropped {
+			t.Errorf("%s: kept %v dropped %d, want %v %d", tt.name, kept, result.Dropped, tt.pids, tt.dropped)
+		}
+
+		// The rewritten PMT lists only the kept streams.
+		remuxed, err := ReadPrograms(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Errorf("%s: ReadPrograms: %v", tt.name, err)
+			continue
+		}
+		var listed []int16
+		for _, p := range remuxed {
+			for _, s := range p.Streams {
+				listed = append(listed, s.Pid)
+			}
+		}
+		if !reflect.DeepEqual(listed, tt.pids) {
+			t.Errorf("%s: PMT lists %v, want %v", tt.name, listed, tt.pids)
+		}
+
+		seen := map[int16]int{}
+		for _, pkt := range packets(out.Bytes()) {
+			pid := pkt.Pid()
+			if n, ok := seen[pid]; ok && continuityCounter(pkt) != (n+1)&0x0F {
+				t.Errorf("%s: PID 0x%04X continuity counter %d after %d", tt.name, pid, continuityCounter(pkt), n)
+			}
+			seen[pid] = continuityCounter(pkt)
+		}
+		var pids []int16
+		for pid := range seen {
+			if pid != PidPAT && pid != testPMTPid {
+				pids = append(pids, pid)
+			}
+		}
+		sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+		if len(pids) == 0 {
+			pids = nil
+		}
+		if !reflect.DeepEqual(pids, tt.pids) {
+			t.Errorf("%s: output has PIDs %v, want %v", tt.name, pids, tt.pids)
+		}
+	}
+}
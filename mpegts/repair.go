@@ -0,0 +1,204 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+type RepairResult struct {
+	Packets         int64
+	Resyncs         int64
+	GarbageBytes    int64
+	TransportErrors int64
+	BrokenPES       int64
+	OrphanPackets   int64
+}
+
+func (r *RepairResult) Changed() bool {
+	return r.Resyncs > 0 || r.TransportErrors > 0 || r.BrokenPES > 0 || r.OrphanPackets > 0
+}
+
+func (r *RepairResult) String() string {
+	return fmt.Sprintf("%d packets written, %d resyncs skipping %d bytes, %d packets with transport errors, %d broken PES starts, %d orphaned PES packets dropped",
+		r.Packets, r.Resyncs, r.GarbageBytes, r.TransportErrors, r.BrokenPES, r.OrphanPackets)
+}
+
+// Number of packets that must follow a sync byte before the stream is
+// considered back in sync.
+const resyncPackets = 3
+
+// syncReader reads packets, skipping over any garbage between them.
+type syncReader struct {
+	r      *bufio.Reader
+	locked bool
+	result *RepairResult
+}
+
+func (s *syncReader) synced() bool {
+	buf, _ := s.r.Peek(resyncPackets * ts.PktLen)
+	if len(buf) == 0 || buf[0] != 0x47 {
+		return false
+	}
+	for i := ts.PktLen; i < len(buf); i += ts.PktLen {
+		if buf[i] != 0x47 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *syncReader) read(pkt []byte) error {
+	if b, err := s.r.Peek(1); err == nil && b[0] != 0x47 {
+		s.locked = false
+	}
+
+	if !s.locked && !s.synced() {
+		skipped := int64(0)
+		for {
+			if _, err := s.r.Peek(1); err != nil {
+				return err
+			}
+			if s.synced() {
+				break
+			}
+			s.r.Discard(1)
+			skipped++
+		}
+		if skipped > 0 {
+			s.result.Resyncs++
+			s.result.GarbageBytes += skipped
+		}
+	}
+	s.locked = true
+
+	_, err := io.ReadFull(s.r, pkt)
+	return err
+}
+
+// validPESStart reports whether pkt starts a PES packet with a start code.
+func validPESStart(pkt ts.Pkt) bool {
+	p := payload(pkt)
+	return len(p) >= 6 && p[0] == 0x00 && p[1] == 0x00 && p[2] == 0x01
+}
+
+func RepairFile(input, output string) (*RepairResult, error) {
+	in, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	programs, err := ReadPrograms(in)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := Repair(out, in, programs)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(output)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Repair copies r to w, resyncing after garbage and dropping packets with the
+// transport error indicator set. PES packets of the elementary streams in
+// programs that start without a start code, or whose start was dropped, are
+// removed up to the next good PES start.
+func Repair(w io.Writer, r io.Reader, programs []*Program) (*RepairResult, error) {
+	var (
+		buf    [ts.PktLen]byte
+		result RepairResult
+	)
+
+	// Whether the current PES packet on each elementary stream is intact.
+	// Section streams are passed through untouched.
+	intact := map[int16]bool{}
+	for _, p := range programs {
+		for _, s := range p.Streams {
+			if s.IsPES() {
+				intact[s.Pid] = false
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	reader := &syncReader{r: bufio.NewReaderSize(r, 64*1024), result: &result}
+
+	for {
+		if err := reader.read(buf[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+		pkt := ts.AsPkt(buf[:])
+
+		pid := pkt.Pid()
+		ok, isPES := intact[pid]
+
+		if transportError(pkt) {
+			result.TransportErrors++
+			if isPES {
+				intact[pid] = false
+			}
+			continue
+		}
+
+		if isPES && hasPayload(pkt) {
+			if payloadUnitStart(pkt) {
+				ok = validPESStart(pkt)
+				intact[pid] = ok
+				if !ok {
+					result.BrokenPES++
+					continue
+				}
+			} else if !ok {
+				result.OrphanPackets++
+				continue
+			}
+		}
+
+		if _, err := bw.Write(pkt.Bytes()); err != nil {
+			return nil, err
+		}
+		result.Packets++
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
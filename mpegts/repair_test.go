@@ -0,0 +1,118 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+const (
+	testVideoPid  = 0x1011
+	testAudioPid  = 0x1100
+	testSCTE35Pid = 0x1200
+)
+
+var testStreams = []testStream{
+	{StreamMPEG2Video, testVideoPid, nil},
+	{StreamAC3, testAudioPid, []byte{descISO639Language, 4, 'e', 'n', 'g', 0}},
+	{StreamSCTE35, testSCTE35Pid, nil},
+}
+
+func testPrograms(t *testing.T) []*Program {
+	programs, err := ReadPrograms(bytes.NewReader(append(patPacket(0), pmtPacket(0, testVideoPid, testStreams)...)))
+	if err != nil {
+		t.Fatalf("ReadPrograms: %v", err)
+	}
+	return programs
+}
+
+// scte35Packet returns a packet with a splice_null section.
+func scte35Packet(cc int) []byte {
+	section := []byte{tableSCTE35, 0x30, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0xF0, 0, 0x00, 0, 0}
+	length := len(section) - 3 + 4
+	section[1], section[2] = 0x30|byte(length>>8), byte(length)
+	crc := crc32(section)
+	return sectionPacket(testSCTE35Pid, cc, append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc)))
+}
+
+func TestRepair(t *testing.T) {
+	es := bytes.Repeat([]byte{0x55}, 300)
+
+	var videoCC int
+	good := pesPackets(testVideoPid, &videoCC, nil, pesPacket(0xE0, 90000, es))
+	broken := pesPackets(testVideoPid, &videoCC, nil, pesPacket(0xE0, 93003, es))
+	broken[1] |= 0x80
+
+	tests := []struct {
+		name   string
+		input  [][]byte
+		want   RepairResult
+		scte35 bool
+	}{
+		{
+			name:   "clean",
+			input:  [][]byte{good, scte35Packet(0), good},
+			want:   RepairResult{Packets: 5},
+			scte35: true,
+		},
+		{
+			name:   "garbage",
+			input:  [][]byte{good, scte35Packet(0), {0x00, 0x12, 0x34}, good, good},
+			want:   RepairResult{Packets: 7, Resyncs: 1, GarbageBytes: 3},
+			scte35: true,
+		},
+		{
+			name:   "transport error",
+			input:  [][]byte{broken, scte35Packet(0), good},
+			want:   RepairResult{Packets: 3, TransportErrors: 1, OrphanPackets: 1},
+			scte35: true,
+		},
+		{
+			name:  "missing start code",
+			input: [][]byte{tsPacket(testVideoPid, true, 0, nil, es[:184]), good},
+			want:  RepairResult{Packets: 2, BrokenPES: 1},
+		},
+	}
+
+	programs := testPrograms(t)
+	for _, tt := range tests {
+		var out bytes.Buffer
+		result, err := Repair(&out, bytes.NewReader(bytes.Join(tt.input, nil)), programs)
+		if err != nil {
+			t.Errorf("%s: Repair: %v", tt.name, err)
+			continue
+		}
+		if *result != tt.want {
+			t.Errorf("%s: Repair = %+v, want %+v", tt.name, *result, tt.want)
+		}
+		if out.Len() != int(result.Packets)*ts.PktLen {
+			t.Errorf("%s: wrote %d bytes for %d packets", tt.name, out.Len(), result.Packets)
+		}
+
+		scte35 := false
+		for _, pkt := range packets(out.Bytes()) {
+			if pkt.Pid() == testSCTE35Pid {
+				scte35 = true
+			}
+		}
+		if scte35 != tt.scte35 {
+			t.Errorf("%s: SCTE-35 packet kept = %v, want %v", tt.name, scte35, tt.scte35)
+		}
+	}
+}
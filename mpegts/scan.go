@@ -0,0 +1,75 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"io"
+	"time"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+type Stats struct {
+	Packets          int64
+	TransportErrors  int64
+	ContinuityErrors int64
+	SyncErrors       int64
+	PCRPid           int16
+	Duration         time.Duration
+	MaxGap           time.Duration
+}
+
+func (s *Stats) Errors() int64 {
+	return s.TransportErrors + s.ContinuityErrors + s.SyncErrors
+}
+
+// Bitrate returns the average bitrate in bits per second.
+func (s *Stats) Bitrate() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Packets*ts.PktLen*8) / s.Duration.Seconds()
+}
+
+func (s *Stats) ContinuityErrorRate() float64 {
+	if s.Packets == 0 {
+		return 0
+	}
+	return float64(s.ContinuityErrors) / float64(s.Packets)
+}
+
+func ScanFile(filename string) (*Stats, error) {
+	report, err := AnalyzeFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &report.Stats, nil
+}
+
+func Scan(r io.Reader) (*Stats, error) {
+	report, err := Analyze(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &report.Stats, nil
+}
+
+func pcrDuration(ticks int64) time.Duration {
+	// ticks * time.Second overflows after a few minutes of PCR.
+	return time.Duration(ticks/pcrClock)*time.Second + time.Duration(ticks%pcrClock)*time.Second/pcrClock
+}
@@ -0,0 +1,61 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPCRDuration(t *testing.T) {
+	tests := []struct {
+		ticks int64
+		want  time.Duration
+	}{
+		{0, 0},
+		{pcrClock / 1000, time.Millisecond},
+		{pcrClock, time.Second},
+		{400 * pcrClock, 400 * time.Second},
+		{3 * 3600 * pcrClock, 3 * time.Hour},
+		{6*3600*pcrClock + pcrClock/2, 6*time.Hour + 500*time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := pcrDuration(tt.ticks); got != tt.want {
+			t.Errorf("pcrDuration(%d) = %v, want %v", tt.ticks, got, tt.want)
+		}
+	}
+}
+
+func TestPTSDuration(t *testing.T) {
+	tests := []struct {
+		ticks int64
+		want  time.Duration
+	}{
+		{0, 0},
+		{ptsClock / 1000, time.Millisecond},
+		{3003, 33366666 * time.Nanosecond},
+		{3 * 3600 * ptsClock, 3 * time.Hour},
+		{30 * 3600 * ptsClock, 30 * time.Hour},
+		{-ptsClock, -time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := ptsDuration(tt.ticks); got != tt.want {
+			t.Errorf("ptsDuration(%d) = %v, want %v", tt.ticks, got, tt.want)
+		}
+	}
+}
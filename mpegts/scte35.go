@@ -0,0 +1,376 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+const (
+	tableSCTE35 = 0xFC
+
+	spliceInsert     = 0x05
+	spliceTimeSignal = 0x06
+
+	descSegmentation = 0x02
+)
+
+// An AdBreak is a range of a recording, relative to the first presentation
+// timestamp, signaled as an advertisement. End is zero when the end of the
+// break was never signaled.
+type AdBreak struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// spliceCue is a decoded splice_info_section. Times are 90kHz PTS values
+// with the pts_adjustment already applied.
+type spliceCue struct {
+	event     spliceEvent
+	out       bool
+	in        bool
+	immediate bool
+	hasTime   bool
+	time      int64
+	duration  int64
+}
+
+// spliceEvent identifies a splice_insert or segmentation event, cues are
+// repeated until the splice point.
+type spliceEvent struct {
+	segmentation bool
+	id           uint32
+}
+
+// spliceTime decodes a splice_time() structure, returning the number of
+// bytes used.
+func spliceTime(data []byte) (pts int64, ok bool, n int) {
+	if len(data) < 1 {
+		return 0, false, 0
+	}
+	if data[0]&0x80 == 0 {
+		return 0, false, 1
+	}
+	if len(data) < 5 {
+		return 0, false, len(data)
+	}
+
+	return int64(data[0]&0x01)<<32 | int64(data[1])<<24 | int64(data[2])<<16 | int64(data[3])<<8 | int64(data[4]), true, 5
+}
+
+func timestamp40(data []byte) int64 {
+	return int64(data[0])<<32 | int64(data[1])<<24 | int64(data[2])<<16 | int64(data[3])<<8 | int64(data[4])
+}
+
+// Segmentation types that start and end an advertisement, in pairs.
+var segmentationTypes = map[byte]bool{
+	0x30: true, 0x31: false, // provider advertisement
+	0x32: true, 0x33: false, // distributor advertisement
+	0x34: true, 0x35: false, // provider placement opportunity
+	0x36: true, 0x37: false, // distributor placement opportunity
+}
+
+func parseSpliceInsert(data []byte, cue *spliceCue) {
+	if len(data) < 5 || data[4]&0x80 != 0 {
+		return
+	}
+	cue.event = spliceEvent{id: uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])}
+	data = data[5:]
+	if len(data) < 1 {
+		return
+	}
+
+	flags := data[0]
+	outOfNetwork := flags&0x80 != 0
+	programSplice := flags&0x40 != 0
+	durationFlag := flags&0x20 != 0
+	cue.immediate = flags&0x10 != 0
+	data = data[1:]
+
+	if programSplice && !cue.immediate {
+		pts, ok, n := spliceTime(data)
+		cue.time, cue.hasTime = pts, ok
+		data = data[n:]
+	} else if !programSplice {
+		if len(data) < 1 {
+			return
+		}
+		count := int(data[0])
+		data = data[1:]
+		for i := 0; i < count && len(data) >= 1; i++ {
+			data = data[1:]
+			if !cue.immediate {
+				pts, ok, n := spliceTime(data)
+				if i == 0 {
+					cue.time, cue.hasTime = pts, ok
+				}
+				data = data[n:]
+			}
+		}
+	}
+
+	if durationFlag && len(data) >= 5 {
+		cue.duration = int64(data[0]&0x01)<<32 | timestamp40(data)&0xFFFFFFFF
+	}
+
+	cue.out = outOfNetwork
+	cue.in = !outOfNetwork
+}
+
+func parseSegmentation(d Descriptor, cue *spliceCue) {
+	data := d.Data
+	if len(data) < 10 || string(data[0:4]) != "CUEI" || data[8]&0x80 != 0 {
+		return
+	}
+
+	event := spliceEvent{segmentation: true, id: uint32(data[4])<<24 | uint32(data[5])<<16 | uint32(data[6])<<8 | uint32(data[7])}
+	flags := data[9]
+	programSegmentation := flags&0x80 != 0
+	durationFlag := flags&0x40 != 0
+	data = data[10:]
+
+	if !programSegmentation {
+		if len(data) < 1 {
+			return
+		}
+		count := int(data[0])
+		if 1+count*6 > len(data) {
+			return
+		}
+		data = data[1+count*6:]
+	}
+
+	var duration int64
+	if durationFlag {
+		if len(data) < 5 {
+			return
+		}
+		duration = timestamp40(data)
+		data = data[5:]
+	}
+
+	if len(data) < 2 {
+		return
+	}
+	upidLen := int(data[1])
+	if 2+upidLen+1 > len(data) {
+		return
+	}
+
+	start, ok := segmentationTypes[data[2+upidLen]]
+	if !ok {
+		return
+	}
+	cue.event = event
+	cue.out, cue.in = start, !start
+	if start {
+		cue.duration = duration
+	}
+}
+
+func parseSpliceInfo(s Section) *spliceCue {
+	data := s[3:]
+	if len(data) < 11 || data[1]&0x80 != 0 {
+		// Encrypted cues are of no use to us.
+		return nil
+	}
+
+	adjustment := int64(data[1]&0x01)<<32 | int64(data[2])<<24 | int64(data[3])<<16 | int64(data[4])<<8 | int64(data[5])
+	commandLen := int(data[8]&0x0F)<<8 | int(data[9])
+	commandType := data[10]
+	data = data[11:]
+	if commandLen > len(data) {
+		return nil
+	}
+	command := data[:commandLen]
+	data = data[commandLen:]
+
+	cue := &spliceCue{}
+	switch commandType {
+	case spliceInsert:
+		parseSpliceInsert(command, cue)
+	case spliceTimeSignal:
+		pts, ok, _ := spliceTime(command)
+		cue.time, cue.hasTime = pts, ok
+		if len(data) >= 2 {
+			descLen := int(data[0])<<8 | int(data[1])
+			if 2+descLen <= len(data) {
+				for _, d := range parseDescriptors(data[2 : 2+descLen]) {
+					if d.Tag == descSegmentation {
+						parseSegmentation(d, cue)
+					}
+				}
+			}
+		}
+	default:
+		return nil
+	}
+
+	if !cue.out && !cue.in {
+		return nil
+	}
+	if cue.hasTime {
+		cue.time = (cue.time + adjustment) % ptsWrap
+	}
+
+	return cue
+}
+
+type cueReader struct {
+	programs *programReader
+	readers  map[int16]*sectionReader
+	firstPTS int64
+	lastPTS  int64
+	havePTS  bool
+	breaks   []AdBreak
+	open     *AdBreak
+	started  map[spliceEvent]bool
+}
+
+// offset returns the time of pts relative to the first timestamp of the
+// stream.
+func (c *cueReader) offset(pts int64) time.Duration {
+	delta := (pts - c.firstPTS%ptsWrap + ptsWrap) % ptsWrap
+	if delta >= ptsWrap/2 {
+		delta -= ptsWrap
+	}
+	return ptsDuration(delta)
+}
+
+func (c *cueReader) cue(cue *spliceCue) {
+	at := c.lastPTS
+	if cue.hasTime && !cue.immediate {
+		at = cue.time
+	}
+	t := c.offset(at)
+	if t < 0 {
+		t = 0
+	}
+
+	if cue.out {
+		if c.open != nil || c.started[cue.event] {
+			return
+		}
+		c.started[cue.event] = true
+		b := AdBreak{Start: t}
+		if cue.duration > 0 {
+			b.End = t + ptsDuration(cue.duration)
+			c.breaks = append(c.breaks, b)
+			return
+		}
+		c.open = &b
+		return
+	}
+
+	if c.open != nil {
+		c.open.End = t
+		c.breaks = append(c.breaks, *c.open)
+		c.open = nil
+	}
+}
+
+func (c *cueReader) push(pkt ts.Pkt) {
+	if transportError(pkt) {
+		return
+	}
+
+	if !c.programs.done() {
+		c.programs.push(pkt)
+		if c.programs.done() {
+			for _, p := range c.programs.list() {
+				for _, s := range p.Streams {
+					if s.Type == StreamSCTE35 {
+						c.readers[s.Pid] = &sectionReader{}
+					}
+				}
+			}
+		}
+	}
+
+	if v, ok := pts(pkt); ok {
+		if !c.havePTS {
+			c.firstPTS, c.havePTS = v, true
+		}
+		c.lastPTS = v
+	}
+
+	reader, ok := c.readers[pkt.Pid()]
+	if !ok || !c.havePTS {
+		return
+	}
+	sections, _ := reader.push(pkt)
+	for _, s := range sections {
+		if s.TableID() != tableSCTE35 {
+			continue
+		}
+		if cue := parseSpliceInfo(s); cue != nil {
+			c.cue(cue)
+		}
+	}
+}
+
+func ReadAdBreaksFile(filename string) ([]AdBreak, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadAdBreaks(file)
+}
+
+// ReadAdBreaks extracts the advertisement breaks signaled by SCTE-35
+// splice_insert and time_signal cues in a stream.
+func ReadAdBreaks(r io.Reader) ([]AdBreak, error) {
+	var buf [ts.PktLen]byte
+
+	c := &cueReader{
+		programs: newProgramReader(),
+		readers:  map[int16]*sectionReader{},
+		started:  map[spliceEvent]bool{},
+	}
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+
+	for {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				continue
+			}
+			return nil, err
+		}
+		c.push(pkt)
+	}
+
+	if c.open != nil {
+		c.breaks = append(c.breaks, *c.open)
+	}
+
+	sort.Slice(c.breaks, func(i, j int) bool {
+		return c.breaks[i].Start < c.breaks[j].Start
+	})
+
+	return c.breaks, nil
+}
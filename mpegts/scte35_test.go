@@ -0,0 +1,156 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// spliceSection builds a splice_info_section around a splice command.
+func spliceSection(adjustment int64, encrypted bool, commandType byte, command, descriptors []byte) []byte {
+	flags := byte(adjustment>>32) & 0x01
+	if encrypted {
+		flags |= 0x80
+	}
+	s := []byte{tableSCTE35, 0, 0, 0, flags,
+		byte(adjustment >> 24), byte(adjustment >> 16), byte(adjustment >> 8), byte(adjustment),
+		0, 0xFF, 0xF0 | byte(len(command)>>8), byte(len(command)), commandType}
+	s = append(s, command...)
+	s = append(s, byte(len(descriptors)>>8), byte(len(descriptors)))
+	s = append(s, descriptors...)
+
+	length := len(s) - 3 + 4
+	s[1], s[2] = 0x30|byte(length>>8), byte(length)
+	crc := crc32(s)
+	return append(s, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+func spliceTimeField(pts int64) []byte {
+	return []byte{0xFE | byte(pts>>32)&0x01, byte(pts >> 24), byte(pts >> 16), byte(pts >> 8), byte(pts)}
+}
+
+// spliceInsertSection returns a program splice_insert, immediate when pts
+// is negative.
+func spliceInsertSection(adjustment int64, id byte, out bool, pts, duration int64) []byte {
+	cmd := []byte{0, 0, 0, id, 0x7F}
+	flags := byte(0x4F)
+	if out {
+		flags |= 0x80
+	}
+	if duration > 0 {
+		flags |= 0x20
+	}
+	if pts < 0 {
+		flags |= 0x10
+	}
+	cmd = append(cmd, flags)
+	if pts >= 0 {
+		cmd = append(cmd, spliceTimeField(pts)...)
+	}
+	if duration > 0 {
+		cmd = append(cmd, spliceTimeField(duration)...)
+	}
+	cmd = append(cmd, 0, 1, 0, 0)
+
+	return spliceSection(adjustment, false, spliceInsert, cmd, nil)
+}
+
+// timeSignalSection returns a time_signal with a segmentation descriptor.
+func timeSignalSection(id byte, pts int64, segmentationType byte, duration int64) []byte {
+	data := []byte{'C', 'U', 'E', 'I', 0, 0, 0, id, 0x7F, 0xBF}
+	if duration > 0 {
+		data[9] |= 0x40
+		data = append(data, byte(duration>>32), byte(duration>>24), byte(duration>>16), byte(duration>>8), byte(duration))
+	}
+	data = append(data, 0, 0, segmentationType, 0, 0)
+
+	return spliceSection(0, false, spliceTimeSignal, spliceTimeField(pts), append([]byte{descSegmentation, byte(len(data))}, data...))
+}
+
+func TestReadAdBreaks(t *testing.T) {
+	const base = 1000 * ptsClock
+	at := func(seconds int64) int64 {
+		return (base + seconds*ptsClock) % ptsWrap
+	}
+
+	tests := []struct {
+		name   string
+		base   int64
+		cues   map[int][][]byte
+		breaks []AdBreak
+	}{
+		{"insert with duration", base, map[int][][]byte{
+			2: {spliceInsertSection(0, 1, true, at(10), 60*ptsClock)},
+		}, []AdBreak{{10 * time.Second, 70 * time.Second}}},
+		{"repeated cues", base, map[int][][]byte{
+			1: {spliceInsertSection(0, 1, true, at(10), 60*ptsClock)},
+			2: {spliceInsertSection(0, 1, true, at(10), 60*ptsClock)},
+			3: {spliceInsertSection(0, 1, true, at(10), 60*ptsClock), timeSignalSection(1, at(11), 0x30, 2*ptsClock)},
+			4: {timeSignalSection(1, at(11), 0x30, 2*ptsClock)},
+			5: {spliceInsertSection(0, 2, true, at(12), 60*ptsClock)},
+		}, []AdBreak{{10 * time.Second, 70 * time.Second}, {11 * time.Second, 13 * time.Second}, {12 * time.Second, 72 * time.Second}}},
+		{"immediate out and in", base, map[int][][]byte{
+			5: {spliceInsertSection(0, 1, true, -1, 0)},
+			8: {spliceInsertSection(0, 1, false, -1, 0)},
+		}, []AdBreak{{5 * time.Second, 8 * time.Second}}},
+		{"pts adjustment", base, map[int][][]byte{
+			1: {spliceInsertSection(at(30), 1, true, 0, 30*ptsClock)},
+		}, []AdBreak{{30 * time.Second, time.Minute}}},
+		{"segmentation", base, map[int][][]byte{
+			3:  {timeSignalSection(1, at(4), 0x34, 0)},
+			10: {timeSignalSection(1, at(12), 0x35, 0)},
+			12: {timeSignalSection(2, at(13), 0x30, 2*ptsClock)},
+		}, []AdBreak{{4 * time.Second, 12 * time.Second}, {13 * time.Second, 15 * time.Second}}},
+		{"never ends", base, map[int][][]byte{
+			12: {spliceInsertSection(0, 1, true, -1, 0)},
+		}, []AdBreak{{12 * time.Second, 0}}},
+		{"across the wrap", ptsWrap - 5*ptsClock, map[int][][]byte{
+			2: {spliceInsertSection(0, 1, true, 5*ptsClock, 10*ptsClock)},
+		}, []AdBreak{{10 * time.Second, 20 * time.Second}}},
+		{"ignored", base, map[int][][]byte{
+			2: {
+				spliceSection(0, true, spliceInsert, nil, nil),
+				timeSignalSection(3, at(3), 0x10, 0),
+				spliceSection(0, false, 0x00, nil, nil),
+			},
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		var cc, scteCC int
+		stream := append(patPacket(0), pmtPacket(0, testVideoPid, testStreams)...)
+		for sec := 0; sec < 15; sec++ {
+			pts := (tt.base + int64(sec)*ptsClock) % ptsWrap
+			stream = append(stream, pesPackets(testVideoPid, &cc, nil, pesPacket(0xE0, pts, []byte{0, 0, 1, 0}))...)
+			for _, s := range tt.cues[sec] {
+				stream = append(stream, sectionPacket(testSCTE35Pid, scteCC, s)...)
+				scteCC = (scteCC + 1) & 0x0F
+			}
+		}
+
+		breaks, err := ReadAdBreaks(bytes.NewReader(stream))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(breaks, tt.breaks) {
+			t.Errorf("%s: got %v, want %v", tt.name, breaks, tt.breaks)
+		}
+	}
+}
@@ -0,0 +1,787 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/ziutek/dvb/ts"
+)
+
+var ErrNoVideo = errors.New("No video parameters found")
+
+type VideoInfo struct {
+	Codec       string
+	Width       int
+	Height      int
+	FrameRate   float64
+	Interlaced  bool
+	AspectRatio float64
+}
+
+// Resolution returns the usual name of the video format, like 1080i or 720p.
+func (v *VideoInfo) Resolution() string {
+	height := v.Height
+	if height == 1088 {
+		height = 1080
+	}
+	if v.Interlaced {
+		return fmt.Sprintf("%di", height)
+	}
+	return fmt.Sprintf("%dp", height)
+}
+
+// Aspect returns the display aspect ratio as 16:9, 4:3 or a decimal ratio.
+func (v *VideoInfo) Aspect() string {
+	switch {
+	case v.AspectRatio == 0:
+		return ""
+	case math.Abs(v.AspectRatio-16.0/9) < 0.05:
+		return "16:9"
+	case math.Abs(v.AspectRatio-4.0/3) < 0.05:
+		return "4:3"
+	}
+	return fmt.Sprintf("%.2f:1", v.AspectRatio)
+}
+
+// Pixels returns the number of pixels per second, for comparing quality.
+func (v *VideoInfo) Pixels() float64 {
+	pixels := float64(v.Width * v.Height)
+	if v.FrameRate > 0 {
+		pixels *= v.FrameRate
+	}
+	return pixels
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+	err  bool
+}
+
+func (b *bitReader) u(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if b.pos >= len(b.data)*8 {
+			b.err = true
+			return 0
+		}
+		v = v<<1 | uint32(b.data[b.pos/8]>>(7-uint(b.pos%8))&1)
+		b.pos++
+	}
+	return v
+}
+
+func (b *bitReader) flag() bool {
+	return b.u(1) == 1
+}
+
+// ue reads an unsigned Exp-Golomb code.
+func (b *bitReader) ue() uint32 {
+	zeros := 0
+	for !b.flag() {
+		if b.err || zeros > 31 {
+			b.err = true
+			return 0
+		}
+		zeros++
+	}
+	return 1<<uint(zeros) - 1 + b.u(zeros)
+}
+
+// se reads a signed Exp-Golomb code.
+func (b *bitReader) se() int32 {
+	v := b.ue()
+	if v&1 != 0 {
+		return int32((v + 1) / 2)
+	}
+	return -int32(v / 2)
+}
+
+var mpeg2FrameRates = []float64{0, 24000.0 / 1001, 24, 25, 30000.0 / 1001, 30, 50, 60000.0 / 1001, 60}
+
+// parseMPEG2Sequence reads the sequence header and extension of MPEG-2
+// video.
+func parseMPEG2Sequence(es []byte) *VideoInfo {
+	i := bytes.Index(es, []byte{0x00, 0x00, 0x01, 0xB3})
+	if i < 0 || len(es) < i+12 {
+		return nil
+	}
+	h := es[i+4:]
+
+	v := &VideoInfo{
+		Codec:      "MPEG-2",
+		Width:      int(h[0])<<4 | int(h[1])>>4,
+		Height:     int(h[1]&0x0F)<<8 | int(h[2]),
+		Interlaced: true,
+	}
+	if rate := int(h[3] & 0x0F); rate < len(mpeg2FrameRates) {
+		v.FrameRate = mpeg2FrameRates[rate]
+	}
+	switch h[3] >> 4 {
+	case 1:
+		v.AspectRatio = float64(v.Width) / float64(v.Height)
+	case 2:
+		v.AspectRatio = 4.0 / 3
+	case 3:
+		v.AspectRatio = 16.0 / 9
+	case 4:
+		v.AspectRatio = 2.21
+	}
+
+	// MPEG-1 video has no sequence extension and is always progressive.
+	ext := bytes.Index(h, []byte{0x00, 0x00, 0x01, 0xB5})
+	if ext < 0 || len(h) < ext+6 || h[ext+4]>>4 != 1 {
+		v.Codec = "MPEG-1"
+		v.Interlaced = false
+	} else {
+		v.Interlaced = h[ext+5]&0x08 == 0
+	}
+
+	return v
+}
+
+// H.264 sample aspect ratios by aspect_ratio_idc.
+var h264SAR = [][2]int{
+	{0, 0}, {1, 1}, {12, 11}, {10, 11}, {16, 11}, {40, 33}, {24, 11}, {20, 11},
+	{32, 11}, {80, 33}, {18, 11}, {15, 11}, {64, 33}, {160, 99}, {4, 3}, {3, 2}, {2, 1},
+}
+
+func skipScalingList(b *bitReader, size int) {
+	last, next := int32(8), int32(8)
+	for i := 0; i < size && !b.err; i++ {
+		if next != 0 {
+			next = (last + b.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// h264Params are the SPS fields needed to read slice headers and picture
+// timing SEI messages.
+type h264Params struct {
+	log2MaxFrameNum  int
+	frameMbsOnly     bool
+	mbaff            bool
+	separatePlanes   bool
+	cpbDpbDelays     bool
+	cpbRemovalLength int
+	dpbOutputLength  int
+	picStruct        bool
+}
+
+// hrd reads hrd_parameters, keeping the lengths of the picture timing
+// delays.
+func (p *h264Params) hrd(b *bitReader) {
+	n := b.ue() + 1
+	b.u(8)
+	for i := uint32(0); i < n && !b.err; i++ {
+		b.ue()
+		b.ue()
+		b.u(1)
+	}
+	b.u(5)
+	p.cpbRemovalLength = int(b.u(5)) + 1
+	p.dpbOutputLength = int(b.u(5)) + 1
+	b.u(5)
+	p.cpbDpbDelays = true
+}
+
+// parseH264SPS reads an H.264 sequence parameter set, without the NAL
+// header byte. Streams that allow field coding aren't necessarily
+// interlaced, Interlaced is only a guess until the pictures are checked
+// with an h264Scan.
+func parseH264SPS(sps []byte) (*VideoInfo, *h264Params) {
+	b := &bitReader{data: unescapeRBSP(sps)}
+	p := &h264Params{}
+
+	profile := b.u(8)
+	b.u(16) // constraint flags and level
+	b.ue()  // seq_parameter_set_id
+
+	chroma := uint32(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chroma = b.ue()
+		if chroma == 3 {
+			p.separatePlanes = b.flag()
+		}
+		b.ue()
+		b.ue()
+		b.u(1)
+		if b.flag() {
+			lists := 8
+			if chroma == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if b.flag() {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					skipScalingList(b, size)
+				}
+			}
+		}
+	}
+
+	p.log2MaxFrameNum = int(b.ue()) + 4
+	switch b.ue() {
+	case 0:
+		b.ue()
+	case 1:
+		b.u(1)
+		b.se()
+		b.se()
+		n := b.ue()
+		for i := uint32(0); i < n && !b.err; i++ {
+			b.se()
+		}
+	}
+	b.ue()
+	b.u(1)
+
+	widthMbs := int(b.ue()) + 1
+	heightMaps := int(b.ue()) + 1
+	p.frameMbsOnly = b.flag()
+	if !p.frameMbsOnly {
+		p.mbaff = b.flag()
+	}
+	b.u(1)
+
+	fieldFactor := 2
+	if p.frameMbsOnly {
+		fieldFactor = 1
+	}
+
+	v := &VideoInfo{
+		Codec:      "H.264",
+		Width:      widthMbs * 16,
+		Height:     fieldFactor * heightMaps * 16,
+		Interlaced: p.mbaff,
+	}
+
+	if b.flag() {
+		cropX, cropY := 1, fieldFactor
+		if chroma == 1 || chroma == 2 {
+			cropX = 2
+		}
+		if chroma == 1 {
+			cropY = 2 * fieldFactor
+		}
+		left, right, top, bottom := int(b.ue()), int(b.ue()), int(b.ue()), int(b.ue())
+		v.Width -= cropX * (left + right)
+		v.Height -= cropY * (top + bottom)
+	}
+
+	if b.flag() {
+		sar := [2]int{1, 1}
+		if b.flag() {
+			idc := int(b.u(8))
+			if idc == 255 {
+				sar = [2]int{int(b.u(16)), int(b.u(16))}
+			} else if idc > 0 && idc < len(h264SAR) {
+				sar = h264SAR[idc]
+			}
+		}
+		if sar[0] > 0 && sar[1] > 0 && v.Height > 0 {
+			v.AspectRatio = float64(v.Width*sar[0]) / float64(v.Height*sar[1])
+		}
+		if b.flag() {
+			b.u(1)
+		}
+		if b.flag() {
+			b.u(4)
+			if b.flag() {
+				b.u(24)
+			}
+		}
+		if b.flag() {
+			b.ue()
+			b.ue()
+		}
+		if b.flag() {
+			tick, scale := b.u(32), b.u(32)
+			if tick > 0 && !b.err {
+				v.FrameRate = float64(scale) / float64(2*tick)
+			}
+			b.u(1)
+		}
+		nalHRD := b.flag()
+		if nalHRD {
+			p.hrd(b)
+		}
+		vclHRD := b.flag()
+		if vclHRD {
+			p.hrd(b)
+		}
+		if nalHRD || vclHRD {
+			b.u(1)
+		}
+		p.picStruct = b.flag() && !b.err
+	}
+
+	if b.err && v.Width == 0 {
+		return nil, nil
+	}
+	return v, p
+}
+
+// h264Scan counts the pictures of an H.264 stream coded as fields or as
+// frames, for streams whose SPS allows field coding. Progressive broadcasts
+// often allow it without using it.
+type h264Scan struct {
+	params   *h264Params
+	pictures int
+	fields   int
+	frames   int
+}
+
+// picture looks at the first slice header and the picture timing SEI of the
+// access unit in es.
+func (s *h264Scan) picture(es []byte) {
+	picStruct := -1
+	fieldPic := false
+	haveSlice := false
+
+	for _, nal := range nalUnits(es) {
+		if len(nal) < 2 {
+			continue
+		}
+		switch nal[0] & 0x1F {
+		case 6:
+			seiMessages(unescapeRBSP(nal[1:]), func(payloadType int, p []byte) {
+				if payloadType == 1 && s.params.picStruct {
+					b := &bitReader{data: p}
+					if s.params.cpbDpbDelays {
+						b.u(s.params.cpbRemovalLength)
+						b.u(s.params.dpbOutputLength)
+					}
+					if v := int(b.u(4)); !b.err {
+						picStruct = v
+					}
+				}
+			})
+		case 1, 5:
+			if haveSlice {
+				continue
+			}
+			haveSlice = true
+			b := &bitReader{data: unescapeRBSP(nal[1:])}
+			b.ue() // first_mb_in_slice
+			b.ue() // slice_type
+			b.ue() // pic_parameter_set_id
+			if s.params.separatePlanes {
+				b.u(2)
+			}
+			b.u(s.params.log2MaxFrameNum)
+			fieldPic = b.flag() && !b.err
+		}
+	}
+	if !haveSlice {
+		return
+	}
+
+	s.pictures++
+	switch {
+	case fieldPic:
+		s.fields++
+	case picStruct >= 1 && picStruct <= 4:
+		// Single fields, or a frame of two fields shown one after the
+		// other.
+		s.fields++
+	case picStruct >= 0:
+		// Frames, possibly repeated for pulldown.
+		s.frames++
+	case !s.params.mbaff:
+		s.frames++
+	}
+}
+
+// interlaced reports whether most of the pictures were fields, falling back
+// to the SPS when there was no picture timing.
+func (s *h264Scan) interlaced(guess bool) bool {
+	if s.fields == 0 && s.frames == 0 {
+		return guess
+	}
+	return s.fields > s.frames
+}
+
+// HEVC uses the H.264 sample aspect ratio table.
+var hevcSAR = h264SAR
+
+// skipHEVCScalingList skips scaling_list_data.
+func skipHEVCScalingList(b *bitReader) {
+	for size := 0; size < 4; size++ {
+		step := 1
+		if size == 3 {
+			step = 3
+		}
+		for matrix := 0; matrix < 6 && !b.err; matrix += step {
+			if !b.flag() {
+				b.ue()
+				continue
+			}
+			coefs := 1 << uint(4+size<<1)
+			if coefs > 64 {
+				coefs = 64
+			}
+			if size > 1 {
+				b.se()
+			}
+			for i := 0; i < coefs && !b.err; i++ {
+				b.se()
+			}
+		}
+	}
+}
+
+// skipHEVCRefPicSets skips the short term reference picture sets of an SPS.
+func skipHEVCRefPicSets(b *bitReader, n int) {
+	deltaPocs := make([]int, n)
+	for i := 0; i < n && !b.err; i++ {
+		if i > 0 && b.flag() {
+			// Predicted from the previous set.
+			b.u(1)
+			b.ue()
+			for j := 0; j <= deltaPocs[i-1] && !b.err; j++ {
+				used := b.flag()
+				if used || b.flag() {
+					deltaPocs[i]++
+				}
+			}
+			continue
+		}
+		negative, positive := int(b.ue()), int(b.ue())
+		if negative > 16 || positive > 16 {
+			b.err = true
+			return
+		}
+		for j := 0; j < negative+positive; j++ {
+			b.ue()
+			b.u(1)
+		}
+		deltaPocs[i] = negative + positive
+	}
+}
+
+// parseHEVCSPS reads an HEVC sequence parameter set, without the NAL header.
+// The frame rate is left to the caller when the SPS has no timing.
+func parseHEVCSPS(sps []byte) *VideoInfo {
+	b := &bitReader{data: unescapeRBSP(sps)}
+
+	b.u(4)
+	subLayers := int(b.u(3))
+	b.u(1)
+
+	// profile_tier_level
+	b.u(8)
+	b.u(32)
+	progressive := b.flag()
+	interlaced := b.flag()
+	b.u(46)
+	b.u(8)
+	profilePresent := make([]bool, subLayers)
+	levelPresent := make([]bool, subLayers)
+	for i := 0; i < subLayers; i++ {
+		profilePresent[i] = b.flag()
+		levelPresent[i] = b.flag()
+	}
+	if subLayers > 0 {
+		for i := subLayers; i < 8; i++ {
+			b.u(2)
+		}
+	}
+	for i := 0; i < subLayers; i++ {
+		if profilePresent[i] {
+			b.u(88)
+		}
+		if levelPresent[i] {
+			b.u(8)
+		}
+	}
+
+	b.ue()
+	chroma := b.ue()
+	if chroma == 3 {
+		b.u(1)
+	}
+
+	v := &VideoInfo{
+		Codec:      "HEVC",
+		Width:      int(b.ue()),
+		Height:     int(b.ue()),
+		Interlaced: interlaced && !progressive,
+	}
+	if b.flag() {
+		subX, subY := 1, 1
+		if chroma == 1 || chroma == 2 {
+			subX = 2
+		}
+		if chroma == 1 {
+			subY = 2
+		}
+		left, right, top, bottom := int(b.ue()), int(b.ue()), int(b.ue()), int(b.ue())
+		v.Width -= subX * (left + right)
+		v.Height -= subY * (top + bottom)
+	}
+	if b.err || v.Width <= 0 || v.Height <= 0 {
+		return nil
+	}
+	v.AspectRatio = float64(v.Width) / float64(v.Height)
+
+	// The rest is only needed for the VUI, a truncated SPS still gives the
+	// picture size.
+	b.ue()
+	b.ue()
+	log2MaxPOC := int(b.ue()) + 4
+	first := subLayers
+	if b.flag() {
+		first = 0
+	}
+	for i := first; i <= subLayers; i++ {
+		b.ue()
+		b.ue()
+		b.ue()
+	}
+	for i := 0; i < 6; i++ {
+		b.ue()
+	}
+	if b.flag() && b.flag() {
+		skipHEVCScalingList(b)
+	}
+	b.u(2)
+	if b.flag() {
+		b.u(8)
+		b.ue()
+		b.ue()
+		b.u(1)
+	}
+	skipHEVCRefPicSets(b, int(b.ue()))
+	if b.flag() {
+		n := int(b.ue())
+		for i := 0; i < n && !b.err; i++ {
+			b.u(log2MaxPOC)
+			b.u(1)
+		}
+	}
+	b.u(2)
+	if b.err || !b.flag() {
+		return v
+	}
+
+	// vui_parameters
+	sar := [2]int{1, 1}
+	if b.flag() {
+		idc := int(b.u(8))
+		if idc == 255 {
+			sar = [2]int{int(b.u(16)), int(b.u(16))}
+		} else if idc > 0 && idc < len(hevcSAR) {
+			sar = hevcSAR[idc]
+		}
+	}
+	if b.flag() {
+		b.u(1)
+	}
+	if b.flag() {
+		b.u(4)
+		if b.flag() {
+			b.u(24)
+		}
+	}
+	if b.flag() {
+		b.ue()
+		b.ue()
+	}
+	b.u(1)
+	fieldSeq := b.flag()
+	b.u(1)
+	if b.flag() {
+		b.ue()
+		b.ue()
+		b.ue()
+		b.ue()
+	}
+	var tick, scale uint32
+	if b.flag() {
+		tick, scale = b.u(32), b.u(32)
+	}
+	if b.err {
+		return v
+	}
+
+	// Field sequences code each field as a picture.
+	if fieldSeq {
+		v.Interlaced = true
+		v.Height *= 2
+	}
+	if sar[0] > 0 && sar[1] > 0 {
+		v.AspectRatio = float64(v.Width*sar[0]) / float64(v.Height*sar[1])
+	}
+	if tick > 0 {
+		v.FrameRate = float64(scale) / float64(tick)
+		if fieldSeq {
+			v.FrameRate /= 2
+		}
+	}
+
+	return v
+}
+
+// parseVideo reads the video format from the sequence header or SPS in es.
+// For H.264 that allows field coding it also returns an h264Scan to find
+// out from the pictures whether the video is interlaced.
+func parseVideo(t StreamType, es []byte) (*VideoInfo, *h264Scan) {
+	switch t {
+	case StreamMPEG1Video, StreamMPEG2Video:
+		return parseMPEG2Sequence(es), nil
+	case StreamH264:
+		for _, nal := range nalUnits(es) {
+			if len(nal) > 1 && nal[0]&0x1F == 7 {
+				v, params := parseH264SPS(nal[1:])
+				if v == nil || params.frameMbsOnly {
+					return v, nil
+				}
+				return v, &h264Scan{params: params}
+			}
+		}
+	case StreamHEVC:
+		for _, nal := range nalUnits(es) {
+			if len(nal) > 2 && nal[0]>>1&0x3F == 33 {
+				return parseHEVCSPS(nal[2:]), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Number of pictures used to estimate the frame rate from timestamps.
+const frameRatePictures = 64
+
+// estimateFrameRate guesses the frame rate from the smallest difference
+// between picture timestamps.
+func estimateFrameRate(pts []int64) float64 {
+	sort.Slice(pts, func(i, j int) bool { return pts[i] < pts[j] })
+
+	min := int64(0)
+	for i := 1; i < len(pts); i++ {
+		if d := pts[i] - pts[i-1]; d > 0 && (min == 0 || d < min) {
+			min = d
+		}
+	}
+	if min == 0 {
+		return 0
+	}
+
+	return float64(ptsClock) / float64(min)
+}
+
+// Limit on the packets read while looking for the video parameters.
+const maxVideoPackets = 200000
+
+func ReadVideoInfoFile(filename string) (*VideoInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadVideoInfo(file)
+}
+
+// ReadVideoInfo reads the format of the first video stream from its sequence
+// header or sequence parameter set.
+func ReadVideoInfo(r io.Reader) (*VideoInfo, error) {
+	var (
+		buf   [ts.PktLen]byte
+		video *Stream
+		info  *VideoInfo
+		scan  *h264Scan
+		pts   []int64
+		pes   pesReader
+	)
+
+	programs := newProgramReader()
+	tsfile := ts.NewPktStreamReader(bufio.NewReader(r))
+
+	for i := 0; i < maxVideoPackets; i++ {
+		pkt := ts.AsPkt(buf[:])
+		if err := tsfile.ReadPkt(pkt); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err == ts.ErrSync {
+				continue
+			}
+			return nil, err
+		}
+
+		if video == nil {
+			programs.push(pkt)
+			if programs.done() {
+				if video = firstVideoStream(programs.list()); video == nil {
+					return nil, ErrNoVideo
+				}
+			}
+			continue
+		}
+
+		if pkt.Pid() != video.Pid || transportError(pkt) {
+			continue
+		}
+
+		data := pes.push(pkt)
+		if data == nil {
+			continue
+		}
+		t, ok, es := parsePES(data)
+		if ok {
+			pts = append(pts, t)
+		}
+		if info == nil {
+			info, scan = parseVideo(video.Type, es)
+		}
+		if scan != nil {
+			scan.picture(es)
+		}
+		if info != nil && (info.FrameRate > 0 || len(pts) >= frameRatePictures) &&
+			(scan == nil || scan.pictures >= frameRatePictures) {
+			break
+		}
+	}
+
+	if info == nil {
+		return nil, ErrNoVideo
+	}
+	if info.FrameRate == 0 {
+		info.FrameRate = estimateFrameRate(pts)
+	}
+	if scan != nil {
+		info.Interlaced = scan.interlaced(info.Interlaced)
+	}
+
+	return info, nil
+}
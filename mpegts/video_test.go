@@ -0,0 +1,368 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mpegts
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// bitWriter builds RBSP payloads for the parser tests.
+type bitWriter struct {
+	bits []bool
+}
+
+func (w *bitWriter) u(n int, v uint32) *bitWriter {
+	for i := n - 1; i >= 0; i-- {
+		w.bits = append(w.bits, v>>uint(i)&1 == 1)
+	}
+	return w
+}
+
+func (w *bitWriter) flag(v bool) *bitWriter {
+	if v {
+		return w.u(1, 1)
+	}
+	return w.u(1, 0)
+}
+
+func (w *bitWriter) ue(v uint32) *bitWriter {
+	n := 0
+	for x := v + 1; x > 1; x >>= 1 {
+		n++
+	}
+	return w.u(n, 0).u(n+1, v+1)
+}
+
+// bytes returns the bits with the RBSP trailing bits and emulation
+// prevention.
+func (w *bitWriter) bytes() []byte {
+	bits := append(append([]bool{}, w.bits...), true)
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+
+	var out []byte
+	zeros := 0
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 0x80 >> uint(j)
+			}
+		}
+		if zeros >= 2 && b <= 3 {
+			out = append(out, 0x03)
+			zeros = 0
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// h264SPS describes the SPS fields the tests vary.
+type h264SPS struct {
+	width, height int
+	frameMbsOnly  bool
+	mbaff         bool
+	cropBottom    uint32
+	sar           uint32
+	hrd           bool
+	picStruct     bool
+}
+
+func (s h264SPS) nal() []byte {
+	w := &bitWriter{}
+	w.u(8, 100).u(16, 0x0028).ue(0)
+	w.ue(1).ue(0).ue(0).u(1, 0).u(1, 0)
+	w.ue(0).ue(2).ue(1).u(1, 0)
+
+	mapHeight := s.height / 16
+	if !s.frameMbsOnly {
+		mapHeight /= 2
+	}
+	w.ue(uint32(s.width/16 - 1)).ue(uint32(mapHeight - 1)).flag(s.frameMbsOnly)
+	if !s.frameMbsOnly {
+		w.flag(s.mbaff)
+	}
+	w.u(1, 1)
+	w.flag(s.cropBottom > 0)
+	if s.cropBottom > 0 {
+		w.ue(0).ue(0).ue(0).ue(s.cropBottom)
+	}
+
+	// VUI
+	w.u(1, 1)
+	w.u(1, 1).u(8, s.sar)
+	w.u(1, 0).u(1, 0).u(1, 0)
+	w.u(1, 1).u(32, 1001).u(32, 60000).u(1, 1)
+	w.flag(s.hrd)
+	if s.hrd {
+		w.ue(0).u(4, 0).u(4, 0).ue(1000).ue(1000).u(1, 0)
+		w.u(5, 23).u(5, 23).u(5, 23).u(5, 24)
+	}
+	w.u(1, 0)
+	if s.hrd {
+		w.u(1, 0)
+	}
+	w.flag(s.picStruct)
+	w.u(1, 0)
+
+	return append([]byte{0x67}, w.bytes()...)
+}
+
+// h264Picture returns an IDR access unit with a picture timing SEI carrying
+// picStruct, unless it is negative.
+func h264Picture(s h264SPS, picStruct int, fieldPic bool) []byte {
+	var es []byte
+	if picStruct >= 0 {
+		w := &bitWriter{}
+		if s.hrd {
+			w.u(24, 0).u(24, 2)
+		}
+		w.u(4, uint32(picStruct)).u(4, 0)
+		sei := w.bytes()
+		sei = append([]byte{0x06, 0x01, byte(len(sei))}, sei...)
+		es = append(es, 0, 0, 0, 1)
+		es = append(es, append(sei, 0x80)...)
+	}
+
+	w := &bitWriter{}
+	w.ue(0).ue(7).ue(0).u(4, 0)
+	if !s.frameMbsOnly {
+		w.flag(fieldPic)
+	}
+	w.ue(0).u(16, 0xFFFF)
+	es = append(es, 0, 0, 0, 1, 0x65)
+	return append(es, w.bytes()...)
+}
+
+func TestParseH264(t *testing.T) {
+	tests := []struct {
+		name       string
+		sps        h264SPS
+		picStruct  int
+		fieldPic   bool
+		resolution string
+		aspect     string
+	}{
+		{
+			name:       "720p allowing fields",
+			sps:        h264SPS{width: 1280, height: 736, mbaff: true, cropBottom: 4, sar: 1, picStruct: true},
+			picStruct:  0,
+			resolution: "720p",
+			aspect:     "16:9",
+		},
+		{
+			name:       "1080i mbaff",
+			sps:        h264SPS{width: 1920, height: 1088, mbaff: true, cropBottom: 2, sar: 1, hrd: true, picStruct: true},
+			picStruct:  3,
+			resolution: "1080i",
+			aspect:     "16:9",
+		},
+		{
+			name:       "1080i field pictures",
+			sps:        h264SPS{width: 1920, height: 1088, cropBottom: 2, sar: 1},
+			picStruct:  -1,
+			fieldPic:   true,
+			resolution: "1080i",
+			aspect:     "16:9",
+		},
+		{
+			name:       "1080p",
+			sps:        h264SPS{width: 1920, height: 1088, frameMbsOnly: true, cropBottom: 4, sar: 1, picStruct: true},
+			picStruct:  0,
+			resolution: "1080p",
+			aspect:     "16:9",
+		},
+		{
+			name:       "480i anamorphic",
+			sps:        h264SPS{width: 720, height: 480, mbaff: true, sar: 5, hrd: true, picStruct: true},
+			picStruct:  4,
+			resolution: "480i",
+			aspect:     "16:9",
+		},
+	}
+
+	for _, tt := range tests {
+		es := append([]byte{0, 0, 0, 1}, tt.sps.nal()...)
+		es = append(es, h264Picture(tt.sps, tt.picStruct, tt.fieldPic)...)
+
+		v, scan := parseVideo(StreamH264, es)
+		if v == nil {
+			t.Errorf("%s: no video info", tt.name)
+			continue
+		}
+		if scan != nil {
+			for i := 0; i < 3; i++ {
+				scan.picture(h264Picture(tt.sps, tt.picStruct, tt.fieldPic))
+			}
+			v.Interlaced = scan.interlaced(v.Interlaced)
+		}
+		if v.Resolution() != tt.resolution || v.Aspect() != tt.aspect || math.Abs(v.FrameRate-29.97) > 0.01 {
+			t.Errorf("%s: %dx%d %s %s %.2f, want %s %s 29.97", tt.name, v.Width, v.Height,
+				v.Resolution(), v.Aspect(), v.FrameRate, tt.resolution, tt.aspect)
+		}
+	}
+}
+
+// hevcSPS describes the SPS fields the tests vary.
+type hevcSPS struct {
+	width, height uint32
+	progressive   bool
+	interlaced    bool
+	scalingList   bool
+	vui           bool
+	sar           uint32
+	fieldSeq      bool
+}
+
+func (s hevcSPS) nal() []byte {
+	w := &bitWriter{}
+	w.u(4, 0).u(3, 0).u(1, 1)
+	w.u(8, 1).u(32, 0x60000000).flag(s.progressive).flag(s.interlaced).u(46, 0).u(8, 123)
+	w.ue(0).ue(1).ue(s.width).ue(s.height).u(1, 0)
+	w.ue(0).ue(0).ue(4)
+	w.u(1, 1).ue(4).ue(2).ue(0)
+	w.ue(0).ue(3).ue(0).ue(3).ue(2).ue(2)
+	w.flag(s.scalingList)
+	if s.scalingList {
+		w.u(1, 1)
+		// Six matrices for each size but only two of the largest.
+		for i := 0; i < 3*6+2; i++ {
+			w.u(1, 0).ue(0)
+		}
+	}
+	w.u(1, 1).u(1, 1).u(1, 0)
+
+	// Two short term reference picture sets, the second predicted.
+	w.ue(2)
+	w.ue(1).ue(0).ue(0).u(1, 1)
+	w.u(1, 1).u(1, 0).ue(0).u(1, 1).u(1, 0).u(1, 1)
+	w.u(1, 0).u(1, 1).u(1, 1)
+
+	w.flag(s.vui)
+	if s.vui {
+		w.u(1, 1).u(8, s.sar)
+		w.u(1, 0)
+		w.u(1, 1).u(4, 5).u(1, 1).u(24, 0x010101)
+		w.u(1, 0)
+		w.u(1, 0).flag(s.fieldSeq).u(1, 0)
+		w.u(1, 0)
+		w.u(1, 1).u(32, 1001).u(32, 60000)
+		w.u(1, 0)
+	}
+
+	return append([]byte{0x42, 0x01}, w.bytes()...)
+}
+
+func TestParseHEVC(t *testing.T) {
+	tests := []struct {
+		name       string
+		sps        hevcSPS
+		resolution string
+		aspect     string
+		frameRate  float64
+	}{
+		{"1080p", hevcSPS{width: 1920, height: 1080, progressive: true, vui: true, sar: 1}, "1080p", "16:9", 59.94},
+		{"1080p anamorphic", hevcSPS{width: 1440, height: 1080, progressive: true, scalingList: true, vui: true, sar: 14}, "1080p", "16:9", 59.94},
+		{"1080i", hevcSPS{width: 1920, height: 1080, interlaced: true}, "1080i", "16:9", 0},
+		{"field sequence", hevcSPS{width: 1920, height: 540, interlaced: true, vui: true, sar: 1, fieldSeq: true}, "1080i", "16:9", 29.97},
+		{"no vui", hevcSPS{width: 3840, height: 2160, progressive: true, interlaced: true}, "2160p", "16:9", 0},
+	}
+
+	for _, tt := range tests {
+		es := append([]byte{0, 0, 0, 1}, tt.sps.nal()...)
+		v, _ := parseVideo(StreamHEVC, es)
+		if v == nil {
+			t.Errorf("%s: no video info", tt.name)
+			continue
+		}
+		if v.Resolution() != tt.resolution || v.Aspect() != tt.aspect || math.Abs(v.FrameRate-tt.frameRate) > 0.01 {
+			t.Errorf("%s: %dx%d %s %s %.2f, want %s %s %.2f", tt.name, v.Width, v.Height,
+				v.Resolution(), v.Aspect(), v.FrameRate, tt.resolution, tt.aspect, tt.frameRate)
+		}
+	}
+}
+
+func mpeg2Sequence(width, height int, aspect, rate byte, progressive bool) []byte {
+	es := []byte{0, 0, 1, 0xB3, byte(width >> 4), byte(width<<4) | byte(height>>8), byte(height), aspect<<4 | rate,
+		0xFF, 0xFF, 0xE0, 0x18}
+	ext := []byte{0, 0, 1, 0xB5, 0x14, 0x82, 0x00, 0x01, 0x00, 0x00}
+	if progressive {
+		ext[5] |= 0x08
+	}
+	return append(es, ext...)
+}
+
+func TestParseMPEG2(t *testing.T) {
+	tests := []struct {
+		name       string
+		es         []byte
+		codec      string
+		resolution string
+		aspect     string
+		frameRate  float64
+	}{
+		{"1080i", mpeg2Sequence(1920, 1088, 3, 4, false), "MPEG-2", "1080i", "16:9", 29.97},
+		{"720p", mpeg2Sequence(1280, 720, 3, 7, true), "MPEG-2", "720p", "16:9", 59.94},
+		{"480i", mpeg2Sequence(704, 480, 2, 4, false), "MPEG-2", "480i", "4:3", 29.97},
+		{"MPEG-1", mpeg2Sequence(352, 240, 2, 4, false)[:12], "MPEG-1", "240p", "4:3", 29.97},
+	}
+
+	for _, tt := range tests {
+		v, _ := parseVideo(StreamMPEG2Video, tt.es)
+		if v == nil {
+			t.Errorf("%s: no video info", tt.name)
+			continue
+		}
+		if v.Codec != tt.codec || v.Resolution() != tt.resolution || v.Aspect() != tt.aspect || math.Abs(v.FrameRate-tt.frameRate) > 0.01 {
+			t.Errorf("%s: %s %s %s %.2f, want %s %s %s %.2f", tt.name, v.Codec, v.Resolution(), v.Aspect(), v.FrameRate,
+				tt.codec, tt.resolution, tt.aspect, tt.frameRate)
+		}
+	}
+}
+
+func TestReadVideoInfo(t *testing.T) {
+	sps := h264SPS{width: 1280, height: 736, mbaff: true, cropBottom: 4, sar: 1, picStruct: true}
+	streams := []testStream{{StreamH264, testVideoPid, nil}}
+
+	var buf bytes.Buffer
+	buf.Write(patPacket(0))
+	buf.Write(pmtPacket(0, testVideoPid, streams))
+	cc := 0
+	for i := 0; i < frameRatePictures+2; i++ {
+		es := h264Picture(sps, 0, false)
+		if i == 0 {
+			es = append(append([]byte{0, 0, 0, 1}, sps.nal()...), es...)
+		}
+		buf.Write(pesPackets(testVideoPid, &cc, nil, pesPacket(0xE0, int64(i)*1501, es)))
+	}
+
+	v, err := ReadVideoInfo(&buf)
+	if err != nil {
+		t.Fatalf("ReadVideoInfo: %v", err)
+	}
+	if v.Codec != "H.264" || v.Resolution() != "720p" {
+		t.Errorf("ReadVideoInfo = %s %s, want H.264 720p", v.Codec, v.Resolution())
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package transcript
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Line struct {
+	Time time.Duration
+	Text string
+}
+
+type Document struct {
+	ProgramID string `json:",omitempty"`
+	Path      string
+	Title     string `json:",omitempty"`
+	Indexed   time.Time
+	Lines     []Line
+	// WordLines has the line of each word position in the document.
+	WordLines []int `json:",omitempty"`
+}
+
+type Match struct {
+	Document *Document
+	Time     time.Duration
+	Text     string
+}
+
+// posting is a word position, the index of the document and of the word in
+// it.
+type posting [2]int
+
+// Index is a full-text index of recording transcripts. The word index is
+// stored along with the documents so it is only rebuilt when a document is
+// replaced.
+type Index struct {
+	Documents []*Document
+	Words     map[string][]posting
+}
+
+func New() *Index {
+	return &Index{Words: map[string][]posting{}}
+}
+
+func Load(filename string) (*Index, error) {
+	idx := New()
+
+	buf, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return idx, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(buf, idx); err != nil {
+		return nil, err
+	}
+	if len(idx.Words) == 0 && len(idx.Documents) > 0 {
+		idx.build()
+	}
+
+	return idx, nil
+}
+
+// Save writes the index to filename, replacing it atomically.
+func (idx *Index) Save(filename string) error {
+	buf, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// Add adds doc to the index, replacing any document with the same path.
+func (idx *Index) Add(doc *Document) {
+	for i, d := range idx.Documents {
+		if d.Path == doc.Path {
+			idx.Documents[i] = doc
+			idx.build()
+			return
+		}
+	}
+	idx.Documents = append(idx.Documents, doc)
+	idx.addWords(len(idx.Documents) - 1)
+}
+
+func words(s string) []string {
+	s = strings.Replace(strings.ToLower(s), "'", "", -1)
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// addWords adds the words of document i to the word index.
+func (idx *Index) addWords(i int) {
+	doc := idx.Documents[i]
+	doc.WordLines = nil
+	for n, l := range doc.Lines {
+		for _, w := range words(l.Text) {
+			idx.Words[w] = append(idx.Words[w], posting{i, len(doc.WordLines)})
+			doc.WordLines = append(doc.WordLines, n)
+		}
+	}
+}
+
+func (idx *Index) build() {
+	idx.Words = map[string][]posting{}
+	for i := range idx.Documents {
+		idx.addWords(i)
+	}
+}
+
+// Search finds the phrase in the indexed transcripts. Phrases may span
+// caption lines, matches are reported at the line the phrase starts on.
+func (idx *Index) Search(phrase string) []Match {
+	query := words(phrase)
+	if len(query) == 0 {
+		return nil
+	}
+
+	// Find the phrase at each position of its first word.
+	following := make([]map[posting]bool, len(query))
+	for i, w := range query[1:] {
+		following[i+1] = map[posting]bool{}
+		for _, p := range idx.Words[w] {
+			following[i+1][p] = true
+		}
+	}
+
+	var matches []Match
+	seen := map[posting]bool{}
+	for _, p := range idx.Words[query[0]] {
+		found := true
+		for i := 1; i < len(query) && found; i++ {
+			found = following[i][posting{p[0], p[1] + i}]
+		}
+		if !found || p[0] >= len(idx.Documents) {
+			continue
+		}
+
+		doc := idx.Documents[p[0]]
+		if p[1] >= len(doc.WordLines) || doc.WordLines[p[1]] >= len(doc.Lines) {
+			continue
+		}
+		line := doc.WordLines[p[1]]
+		if key := (posting{p[0], line}); !seen[key] {
+			seen[key] = true
+			l := doc.Lines[line]
+			matches = append(matches, Match{Document: doc, Time: l.Time, Text: l.Text})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Document.Path != b.Document.Path {
+			return a.Document.Path < b.Document.Path
+		}
+		return a.Time < b.Time
+	})
+
+	return matches
+}
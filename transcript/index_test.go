@@ -0,0 +1,108 @@
+// Copyright © 2018 Nathan Caldwell <saintdev@gmail.com>
+//
+// This program is free software; you can redistribute it and/or
+// modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; either version 2
+// of the License, or (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package transcript
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testIndex() *Index {
+	idx := New()
+	idx.Add(&Document{Path: "/a.mkv", Lines: []Line{
+		{Time: time.Second, Text: "Previously on the show"},
+		{Time: 3 * time.Second, Text: "We're going to"},
+		{Time: 5 * time.Second, Text: "need a bigger boat."},
+	}})
+	idx.Add(&Document{Path: "/b.mkv", Lines: []Line{
+		{Time: 2 * time.Second, Text: "A BIGGER BOAT!"},
+		{Time: 4 * time.Second, Text: "Bigger. Boat."},
+	}})
+	return idx
+}
+
+type result struct {
+	Path string
+	Time time.Duration
+}
+
+func results(matches []Match) []result {
+	var r []result
+	for _, m := range matches {
+		r = append(r, result{m.Document.Path, m.Time})
+	}
+	return r
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		phrase string
+		want   []result
+	}{
+		{"bigger boat", []result{{"/a.mkv", 5 * time.Second}, {"/b.mkv", 2 * time.Second}, {"/b.mkv", 4 * time.Second}}},
+		{"going to need", []result{{"/a.mkv", 3 * time.Second}}},
+		{"were going", []result{{"/a.mkv", 3 * time.Second}}},
+		{"boat need", nil},
+		{"shark", nil},
+		{"...", nil},
+	}
+
+	idx := testIndex()
+
+	dir, err := ioutil.TempDir("", "transcript")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "index.json")
+	if err = idx.Save(filename); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := Load(filename)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Words, idx.Words) {
+		t.Errorf("Load didn't restore the word index")
+	}
+
+	for _, tt := range tests {
+		for name, idx := range map[string]*Index{"built": idx, "loaded": loaded} {
+			if got := results(idx.Search(tt.phrase)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: Search(%q) = %v, want %v", name, tt.phrase, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestAddReplaces(t *testing.T) {
+	idx := testIndex()
+	idx.Add(&Document{Path: "/a.mkv", Lines: []Line{{Time: time.Minute, Text: "Shark"}}})
+
+	if len(idx.Documents) != 2 {
+		t.Fatalf("%d documents, want 2", len(idx.Documents))
+	}
+	if got := results(idx.Search("bigger boat")); len(got) != 2 || got[0].Path != "/b.mkv" {
+		t.Errorf("Search found replaced document: %v", got)
+	}
+	if got := results(idx.Search("shark")); !reflect.DeepEqual(got, []result{{"/a.mkv", time.Minute}}) {
+		t.Errorf("Search(shark) = %v", got)
+	}
+}